@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/dlasky/gotk3-layershell/layershell"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+var animations = []string{"none", "slide", "fade"}
+
+// ~60 fps
+const frameInterval = 16
+
+// Shows the dock w/ the -an animation; reverses the hiding animation, if in progress
+func (d *dock) show() {
+	d.hiding = false
+	if !d.win.IsVisible() {
+		d.setProgress(0)
+		d.win.ShowAll()
+	}
+	d.animate(1, func() {})
+}
+
+func (d *dock) hide() {
+	if !d.isShown() {
+		return
+	}
+	d.hiding = true
+	d.animate(0, func() {
+		d.hiding = false
+		d.win.Hide()
+		// ready to be shown w/o animation
+		d.setProgress(1)
+	})
+}
+
+// The window stays visible while the hiding animation runs, but the dock is on its way out
+func (d *dock) isShown() bool {
+	return d.win.IsVisible() && !d.hiding
+}
+
+// Animates the dock towards shown (1) or hidden (0), from where the previous animation left it
+func (d *dock) animate(target float64, done func()) {
+	if d.animSrc > 0 {
+		glib.SourceRemove(d.animSrc)
+		d.animSrc = 0
+	}
+	from := d.progress
+	duration := time.Duration(math.Abs(target-from) * float64(*animationTime) * float64(time.Millisecond))
+	if *animation == "none" || duration <= 0 {
+		d.setProgress(target)
+		done()
+		return
+	}
+
+	start := time.Now()
+	d.animSrc = glib.TimeoutAdd(uint(frameInterval), func() bool {
+		t := min(float64(time.Since(start))/float64(duration), 1)
+		// ease out cubic
+		d.setProgress(from + (target-from)*(1-math.Pow(1-t, 3)))
+		if t < 1 {
+			return true
+		}
+		d.animSrc = 0
+		done()
+		return false
+	})
+}
+
+// Slides the dock out from behind the screen edge, or fades it in
+func (d *dock) setProgress(p float64) {
+	d.progress = p
+	switch *animation {
+	case "slide":
+		edge, margin := d.edgeMargin()
+		w, h := d.win.GetSize()
+		size := h
+		if *position == "left" || *position == "right" {
+			size = w
+		}
+		layershell.SetMargin(d.win, edge, margin-int(math.Round((1-p)*float64(size+margin))))
+	case "fade":
+		d.outerBox.SetOpacity(p)
+	}
+}
+
+// The screen edge the dock is anchored to, and its margin
+func (d *dock) edgeMargin() (layershell.LayerShellEdgeFlags, int) {
+	edge := layershell.LAYER_SHELL_EDGE_BOTTOM
+	switch *position {
+	case "top":
+		edge = layershell.LAYER_SHELL_EDGE_TOP
+	case "left":
+		edge = layershell.LAYER_SHELL_EDGE_LEFT
+	case "right":
+		edge = layershell.LAYER_SHELL_EDGE_RIGHT
+	}
+	return edge, d.margin(edge)
+}
+
+// Shows the dock after the -sd delay, unless the pointer leaves the hot spot meanwhile
+func (d *dock) scheduleShow() {
+	d.cancelShow()
+	if *showDelay <= 0 {
+		d.show()
+		return
+	}
+	d.showSrc = glib.TimeoutAdd(uint(*showDelay), func() bool {
+		d.showSrc = 0
+		d.show()
+		return false
+	})
+}
+
+func (d *dock) cancelShow() {
+	if d.showSrc > 0 {
+		glib.SourceRemove(d.showSrc)
+		d.showSrc = 0
+	}
+}
+
+// Hides the dock after the -cd delay, unless the pointer comes back, or a menu is open
+func (d *dock) scheduleClose() {
+	if (!*autohide && !d.overlapped) || d.menuOpen {
+		return
+	}
+	d.cancelClose()
+	d.src = glib.TimeoutAdd(uint(*closeDelay), func() bool {
+		d.src = 0
+		if *autohide {
+			d.hide()
+		} else if d.overlapped {
+			d.intellihide()
+		}
+		return false
+	})
+}
+
+// Leaving the dock for its menu is not leaving it: we only start counting down once the menu is closed
+func (d *dock) popup(menu *gtk.Menu, button *gtk.Button) {
+	d.menuOpen = true
+	d.cancelClose()
+	menu.Connect("deactivate", func() {
+		d.menuOpen = false
+		if !d.hovered {
+			d.scheduleClose()
+		}
+	})
+	menu.PopupAtWidget(button, widgetAnchor, menuAnchor, nil)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Effective configuration, as printed by the `check` subcommand
+type dockConfig struct {
+	Version           string         `json:"version"`
+	Instance          string         `json:"instance"`
+	Mode              string         `json:"mode"`
+	Position          string         `json:"position"`
+	Alignment         string         `json:"alignment"`
+	Layer             string         `json:"layer"`
+	Exclusive         bool           `json:"exclusive"`
+	Full              bool           `json:"full"`
+	IconSize          int            `json:"iconSize"`
+	HotspotDelay      int64          `json:"hotspotDelay"`
+	Margins           map[string]int `json:"margins"`
+	Output            string         `json:"output"`
+	MultiMonitor      bool           `json:"multiMonitor"`
+	PinnedPerMonitor  bool           `json:"pinnedPerMonitor"`
+	FullscreenHide    bool           `json:"fullscreenHide"`
+	ShowDelay         int            `json:"showDelay"`
+	CloseDelay        int            `json:"closeDelay"`
+	Animation         string         `json:"animation"`
+	AnimationTime     int            `json:"animationTime"`
+	HotspotPlace      string         `json:"hotspotPlace"`
+	HotspotLength     int            `json:"hotspotLength"`
+	HotspotThickness  int            `json:"hotspotThickness"`
+	HotspotPressure   int            `json:"hotspotPressure"`
+	ReservedAreas     bool           `json:"reservedAreas"`
+	DesktopButton     bool           `json:"desktopButton"`
+	MinimizeWorkspace string         `json:"minimizeWorkspace"`
+	Workspaces        int64          `json:"workspaces"`
+	IgnoredWorkspaces []string       `json:"ignoredWorkspaces"`
+	LauncherCmd       string         `json:"launcherCommand"`
+	LauncherPos       string         `json:"launcherPosition"`
+	LauncherIcon      string         `json:"launcherIcon"`
+	DataHome          string         `json:"dataHome"`
+	ConfigDir         string         `json:"configDir"`
+	CssFile           string         `json:"cssFile"`
+	PinnedFile        string         `json:"pinnedFile"`
+	Pinned            []string       `json:"pinned"`
+	RulesFile         string         `json:"rulesFile"`
+	Rules             []appRule      `json:"rules"`
+	Problems          []string       `json:"problems"`
+}
+
+// Returns descriptions of invalid argument values, which otherwise fall through to defaults
+func validateFlags() []string {
+	var problems []string
+	enums := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"a", *alignment, []string{"start", "center", "end"}},
+		{"hp", *hotspotPlace, hotspotPlaces},
+		{"an", *animation, animations},
+		{"l", *layer, []string{"overlay", "top", "bottom"}},
+		{"lf", *logFormat, logFormats},
+		{"lp", *launcherPos, []string{"start", "end"}},
+		{"p", *position, []string{"bottom", "top", "left", "right"}},
+	}
+	for _, e := range enums {
+		if !isIn(e.allowed, e.value) {
+			problems = append(problems, fmt.Sprintf("-%s: unknown value '%s', expected one of: %s", e.name, e.value,
+				strings.Join(e.allowed, ", ")))
+		}
+	}
+
+	if *imgSize <= 0 {
+		problems = append(problems, fmt.Sprintf("-i: icon size must be positive, got %v", *imgSize))
+	}
+	if *hotspotDelay < 0 {
+		problems = append(problems, fmt.Sprintf("-hd: hotspot delay can't be negative, got %v", *hotspotDelay))
+	}
+	if *hotspotThickness < 1 {
+		problems = append(problems, fmt.Sprintf("-ht: hotspot thickness must be positive, got %v", *hotspotThickness))
+	}
+	if *hotspotPressure < 1 {
+		problems = append(problems, fmt.Sprintf("-hr: hotspot pressure must be positive, got %v", *hotspotPressure))
+	}
+	if *hotspotLength < 0 {
+		problems = append(problems, fmt.Sprintf("-hl: hotspot length can't be negative, got %v", *hotspotLength))
+	}
+	delays := map[string]int{"at": *animationTime, "cd": *closeDelay, "sd": *showDelay}
+	for _, name := range []string{"at", "cd", "sd"} {
+		if delays[name] < 0 {
+			problems = append(problems, fmt.Sprintf("-%s: time can't be negative, got %v", name, delays[name]))
+		}
+	}
+	if *minimizeWorkspace == "" || strings.Contains(*minimizeWorkspace, ":") {
+		problems = append(problems, fmt.Sprintf("-mw: special workspace name must be non-empty, w/o \":\", got %q", *minimizeWorkspace))
+	}
+	if *numWS < 1 {
+		problems = append(problems, fmt.Sprintf("-w: number of workspaces must be positive, got %v", *numWS))
+	}
+	if *pinnedPerMonitor && !*multiMonitor {
+		problems = append(problems, "-pm: pinned items per monitor need the multi-monitor mode (-mm)")
+	}
+	margins := map[string]int{"mb": *marginBottom, "ml": *marginLeft, "mr": *marginRight, "mt": *marginTop}
+	for _, name := range []string{"mb", "ml", "mr", "mt"} {
+		if margins[name] < 0 {
+			problems = append(problems, fmt.Sprintf("-%s: margin can't be negative, got %v", name, margins[name]))
+		}
+	}
+	return problems
+}
+
+// Validates arguments and config files w/o changing files or connecting to Hyprland, prints the effective configuration.
+// Returns the exit code.
+func checkConfig() int {
+	problems := validateFlags()
+	if *autohide && *resident {
+		problems = append(problems, "-d and -r are mutually exclusive, -d will be ignored")
+	}
+	if *intellihide && *autohide {
+		problems = append(problems, "-d and -ih are mutually exclusive, -ih will be ignored")
+	}
+	if *reservedAreas && (*exclusive || *layer != "overlay") {
+		problems = append(problems, "-ra has no effect w/ -x, or on the top and bottom layers, which avoid reserved space anyway")
+	}
+	if *multiMonitor && *targetOutput != "" {
+		problems = append(problems, "-mm and -o are mutually exclusive, -o will be ignored")
+	}
+	if !isValidInstanceName(*instanceName) {
+		problems = append(problems, fmt.Sprintf("-name: invalid instance name '%s', use letters, digits, '-' and '_' only",
+			*instanceName))
+	}
+
+	cfg := dockConfig{
+		Version:      version,
+		Instance:     *instanceName,
+		Mode:         "default",
+		Position:     *position,
+		Alignment:    *alignment,
+		Layer:        *layer,
+		Exclusive:    *exclusive,
+		Full:         *full,
+		IconSize:     *imgSize,
+		HotspotDelay: *hotspotDelay,
+		Margins: map[string]int{"top": *marginTop, "bottom": *marginBottom, "left": *marginLeft,
+			"right": *marginRight},
+		Output:           *targetOutput,
+		MultiMonitor:     *multiMonitor,
+		PinnedPerMonitor: *pinnedPerMonitor,
+		FullscreenHide:   *fullscreenHide,
+		ShowDelay:        *showDelay,
+		CloseDelay:       *closeDelay,
+		Animation:        *animation,
+		AnimationTime:    *animationTime,
+		HotspotPlace:     *hotspotPlace,
+		HotspotLength:    *hotspotLength,
+		HotspotThickness: *hotspotThickness,
+		HotspotPressure:  *hotspotPressure,
+		ReservedAreas:    *reservedAreas,
+		DesktopButton:    *desktopButton,
+		Workspaces:       *numWS,
+		LauncherPos:      *launcherPos,
+		LauncherIcon:     *ico,
+		ConfigDir:        configDir(),
+	}
+	if *intellihide && !*autohide {
+		cfg.Mode = "intellihide"
+	} else if *resident {
+		cfg.Mode = "resident"
+	} else if *autohide {
+		cfg.Mode = "autohide"
+	}
+	if *exclusive {
+		cfg.Layer = "top"
+	}
+	cfg.MinimizeWorkspace = minimizedWorkspace()
+	if *ignoreWorkspaces != "" {
+		cfg.IgnoredWorkspaces = strings.Split(*ignoreWorkspaces, ",")
+	}
+
+	if !*noLauncher {
+		cfg.LauncherCmd = *launcherCmd
+		if cfg.LauncherCmd == "" {
+			cfg.LauncherCmd = detectLauncherCmd()
+		}
+		if cfg.LauncherCmd != "" && !isCommand(cfg.LauncherCmd) {
+			problems = append(problems, fmt.Sprintf("launcher command not found: '%s'", cfg.LauncherCmd))
+		}
+	}
+
+	var err error
+	cfg.DataHome, err = getDataHome()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	cfg.CssFile = filepath.Join(cfg.ConfigDir, effectiveCssFileName())
+	if !pathExists(cfg.CssFile) {
+		if cfg.CssFile == filepath.Join(cfg.ConfigDir, "style.css") || *instanceName != "" {
+			// will be copied from the data directory on startup
+			if cfg.DataHome == "" || !pathExists(filepath.Join(cfg.DataHome, "nwg-dock-hyprland/style.css")) {
+				problems = append(problems, fmt.Sprintf("css file not found, and no default to copy: %s", cfg.CssFile))
+			}
+		} else {
+			problems = append(problems, fmt.Sprintf("css file not found: %s", cfg.CssFile))
+		}
+	}
+
+	cacheDirectory := cacheDir()
+	if cacheDirectory == "" {
+		problems = append(problems, "couldn't determine cache directory location")
+	} else {
+		cfg.PinnedFile = filepath.Join(cacheDirectory, instanceID("nwg-dock-pinned"))
+		cfg.Pinned, _ = loadTextFile(cfg.PinnedFile)
+	}
+
+	cfg.RulesFile = filepath.Join(cfg.ConfigDir, *rulesFileName)
+	data, err := os.ReadFile(cfg.RulesFile)
+	if err == nil {
+		var ruleProblems []string
+		cfg.Rules, ruleProblems, err = parseRules(data)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", cfg.RulesFile, err))
+		}
+		for _, problem := range ruleProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", cfg.RulesFile, problem))
+		}
+	} else if isFlagPassed("rules") {
+		problems = append(problems, fmt.Sprintf("rules file: %s", err))
+	}
+
+	cfg.Problems = problems
+
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(out))
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "problem: %s\n", problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
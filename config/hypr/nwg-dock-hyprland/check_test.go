@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+// Sets command line flags for the duration of the test
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Fatalf("no such flag: -%s", name)
+		}
+		old := f.Value.String()
+		t.Cleanup(func() { _ = flag.Set(name, old) })
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("-%s %q: %s", name, value, err)
+		}
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		// substrings of expected problems, in order
+		problems []string
+	}{
+		{"defaults", nil, nil},
+		{"valid values", map[string]string{"p": "right", "a": "start", "l": "top", "i": "32", "mb": "8"}, nil},
+		{"unknown position", map[string]string{"p": "bottm"}, []string{"-p: unknown value 'bottm'"}},
+		{"unknown layer", map[string]string{"l": "background"}, []string{"-l: unknown value 'background'"}},
+		{"icon size", map[string]string{"i": "0"}, []string{"-i: icon size must be positive"}},
+		{"hotspot delay", map[string]string{"hd": "-1"}, []string{"-hd:"}},
+		{"workspaces", map[string]string{"w": "0"}, []string{"-w:"}},
+		{"negative margins", map[string]string{"ml": "-1", "mt": "-2"}, []string{"-ml:", "-mt:"}},
+		{"pinned per monitor", map[string]string{"pm": "true"}, []string{"-pm:"}},
+		{"pinned per monitor w/ -mm", map[string]string{"pm": "true", "mm": "true"}, nil},
+		{"unknown animation", map[string]string{"an": "bounce"}, []string{"-an: unknown value 'bounce'"}},
+		{"negative times", map[string]string{"at": "-1", "sd": "-5", "cd": "-5"}, []string{"-at:", "-cd:", "-sd:"}},
+		{"unknown hotspot place", map[string]string{"hp": "edge"}, []string{"-hp: unknown value 'edge'"}},
+		{"hotspot geometry", map[string]string{"ht": "0", "hl": "-1"}, []string{"-ht:", "-hl:"}},
+		{"hotspot pressure", map[string]string{"hr": "0"}, []string{"-hr:"}},
+		{"minimize workspace prefix", map[string]string{"mw": "special:minimized"}, []string{"-mw:"}},
+		{"empty minimize workspace", map[string]string{"mw": ""}, []string{"-mw:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			problems := validateFlags()
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("got problems %q, want %q", problems, tt.problems)
+			}
+			for i, p := range tt.problems {
+				if !strings.Contains(problems[i], p) {
+					t.Errorf("problem #%v: got %q, want it to contain %q", i+1, problems[i], p)
+				}
+			}
+		})
+	}
+}
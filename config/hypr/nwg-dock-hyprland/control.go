@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+const ctlCommands = "show, hide, toggle, focus, activate <index|id>, pin <id>, unpin <id>, show-desktop, reload, list-items, list-pinned, watch, quit"
+
+// Pinned item or group of running clients, as shown on the dock
+type dockItem struct {
+	ID        string
+	Pinned    bool
+	Instances []client
+	box       *gtk.Box
+	button    *gtk.Button
+}
+
+// Dock item description, as returned to control clients
+type itemInfo struct {
+	Index     int      `json:"index"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Icon      string   `json:"icon"`
+	Pinned    bool     `json:"pinned"`
+	Instances int      `json:"instances"`
+	Addresses []string `json:"addresses"`
+}
+
+// Reply to a control command, one json document per line
+type ctlReply struct {
+	Ok      bool        `json:"ok"`
+	Command string      `json:"command"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// The control socket lives in $XDG_RUNTIME_DIR, next to the lock file otherwise
+func controlSocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = tempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.sock", userInstanceID()))
+}
+
+// Starts listening on the control socket; closing the listener removes the socket file
+func startControlServer() (*net.UnixListener, error) {
+	path := controlSocketPath()
+	// we hold the lock file, so the socket left here is stale
+	if pathExists(path) {
+		_ = os.Remove(path)
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Control socket: %s", path)
+
+	go func() {
+		for {
+			conn, err := listener.AcceptUnix()
+			if err != nil {
+				log.Debugf("Control socket closed: %s", err)
+				return
+			}
+			go handleCtlConn(conn)
+		}
+	}()
+	return listener, nil
+}
+
+func handleCtlConn(conn *net.UnixConn) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Warnf("Error reading from control socket: %s", err)
+		return
+	}
+
+	if strings.TrimSpace(line) == "watch" {
+		watchState(conn)
+		return
+	}
+
+	reply := handleCtlCommand(line)
+	out, _ := json.Marshal(reply)
+	_, err = conn.Write(append(out, '\n'))
+	if err != nil {
+		log.Warnf("Error writing to control socket: %s", err)
+	}
+
+	if reply.Command == "quit" && reply.Ok {
+		glib.IdleAdd(gtk.MainQuit)
+	}
+}
+
+func handleCtlCommand(line string) ctlReply {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ctlReply{Error: fmt.Sprintf("no command, expected one of: %s", ctlCommands)}
+	}
+	return runCtlCommand(fields[0], fields[1:]...)
+}
+
+// Runs a command of the control socket or of the D-Bus service
+func runCtlCommand(command string, args ...string) ctlReply {
+	log.WithFields(log.Fields{"command": command, "args": strings.Join(args, " ")}).Debug("Control command")
+
+	switch command {
+	case "show", "hide", "toggle":
+		return inMainLoop(func() ctlReply {
+			return setVisibility(command)
+		})
+	case "focus":
+		return inMainLoop(func() ctlReply {
+			d := focusedDock()
+			if d == nil {
+				return ctlReply{Command: command, Error: "no dock"}
+			}
+			d.enterKeyboardMode()
+			return ctlReply{Ok: true, Command: command}
+		})
+	case "activate":
+		if len(args) != 1 {
+			return ctlReply{Command: command, Error: "usage: activate <index|id>"}
+		}
+		return inMainLoop(func() ctlReply {
+			reply := ctlReply{Command: command}
+			d := focusedDock()
+			if d == nil {
+				reply.Error = "no dock"
+				return reply
+			}
+			item, err := d.findItem(args[0])
+			if err != nil {
+				reply.Error = err.Error()
+				return reply
+			}
+			reply.Ok = true
+			reply.Data = map[string]string{"id": item.ID, "action": activateItem(item)}
+			return reply
+		})
+	case "pin", "unpin":
+		if len(args) != 1 {
+			return ctlReply{Command: command, Error: fmt.Sprintf("usage: %s <id>", command)}
+		}
+		return inMainLoop(func() ctlReply {
+			reply := ctlReply{Command: command}
+			d := focusedDock()
+			if d == nil {
+				reply.Error = "no dock"
+				return reply
+			}
+			if command == "pin" {
+				if d.isPinned(args[0]) {
+					reply.Error = fmt.Sprintf("%s already pinned", args[0])
+					return reply
+				}
+				d.pin(args[0])
+			} else {
+				if !d.isPinned(args[0]) {
+					reply.Error = fmt.Sprintf("%s not pinned", args[0])
+					return reply
+				}
+				d.unpin(args[0])
+			}
+			refreshMainBox(true)
+			reply.Ok = true
+			reply.Data = append([]string{}, d.pinned...)
+			return reply
+		})
+	case "show-desktop":
+		reply := ctlReply{Command: command}
+		action, err := toggleDesktop()
+		if err != nil {
+			reply.Error = err.Error()
+			return reply
+		}
+		reply.Ok = true
+		reply.Data = map[string]string{"action": action}
+		return reply
+	case "reload":
+		return inMainLoop(func() ctlReply {
+			reply := ctlReply{Command: command}
+			reloadConfig()
+			err := listClients()
+			if err != nil {
+				reply.Error = fmt.Sprintf("couldn't list clients: %s", err)
+				return reply
+			}
+			refreshMainBox(true)
+			reply.Ok = true
+			return reply
+		})
+	case "list-items":
+		return inMainLoop(func() ctlReply {
+			d := focusedDock()
+			if d == nil {
+				return ctlReply{Command: command, Error: "no dock"}
+			}
+			return ctlReply{Ok: true, Command: command, Data: d.listItems()}
+		})
+	case "list-pinned":
+		return inMainLoop(func() ctlReply {
+			d := focusedDock()
+			if d == nil {
+				return ctlReply{Command: command, Error: "no dock"}
+			}
+			return ctlReply{Ok: true, Command: command, Data: append([]string{}, d.pinned...)}
+		})
+	case "quit":
+		return ctlReply{Ok: true, Command: command}
+	}
+	return ctlReply{Command: command, Error: fmt.Sprintf("unknown command, expected one of: %s", ctlCommands)}
+}
+
+// Runs f in the GTK main loop, and waits for the result; tests w/o the main loop run f directly
+var inMainLoop = func(f func() ctlReply) ctlReply {
+	result := make(chan ctlReply, 1)
+	glib.IdleAdd(func() bool {
+		result <- f()
+		return false
+	})
+	return <-result
+}
+
+// Same as signals: only resident docks may be shown or hidden; all at a time in the multi-monitor mode
+func setVisibility(command string) ctlReply {
+	reply := ctlReply{Command: command}
+	if !*resident && !*autohide {
+		reply.Error = "not resident, ignoring"
+		return reply
+	}
+
+	visible := docksVisible()
+	if command == "show" || (command == "toggle" && !visible) {
+		showDocks()
+	} else {
+		hideDocks()
+	}
+	reply.Ok = true
+	reply.Data = map[string]bool{"visible": docksVisible()}
+	return reply
+}
+
+// Finds the dock item by its index as shown on the dock (counting from 1), by ID, or by desktop ID
+func (d *dock) findItem(arg string) (dockItem, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(d.items) {
+			return dockItem{}, fmt.Errorf("no item #%v, the dock shows %v", n, len(d.items))
+		}
+		return d.items[n-1], nil
+	}
+	for _, item := range d.items {
+		if strings.EqualFold(item.ID, arg) || strings.EqualFold(desktopID(item.ID), arg) {
+			return item, nil
+		}
+	}
+	return dockItem{}, fmt.Errorf("%s not on the dock", arg)
+}
+
+func (d *dock) listItems() []itemInfo {
+	var result []itemInfo
+	for i, item := range d.items {
+		icon, _ := getItemIcon(item.ID)
+		info := itemInfo{
+			Index:     i + 1,
+			ID:        item.ID,
+			Name:      getName(item.ID),
+			Icon:      icon,
+			Pinned:    item.Pinned,
+			Instances: len(item.Instances),
+			Addresses: []string{},
+		}
+		for _, c := range item.Instances {
+			info.Addresses = append(info.Addresses, c.Address)
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// Re-reads the rules and the style sheet; pinned items are being re-read on every main box rebuild anyway
+func reloadConfig() {
+	var err error
+	rulesFile := filepath.Join(configDirectory, *rulesFileName)
+	rules, err = loadRules(rulesFile)
+	if err != nil {
+		log.Debugf("No rules loaded from %s: %s", rulesFile, err)
+	}
+
+	err = cssProvider.LoadFromPath(cssFile)
+	if err != nil {
+		log.Warnf("Couldn't reload %s: %s", cssFile, err)
+	}
+}
+
+// Sends a command to the running dock instance, prints the reply. Returns the exit code.
+func ctl(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: nwg-dock-hyprland ctl [-name <instance>] <command>\nCommands: %s\n", ctlCommands)
+		return 2
+	}
+
+	conn, err := net.Dial("unix", controlSocketPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't connect to the dock: %s\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(args, " ") + "\n"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't send the command: %s\n", err)
+		return 1
+	}
+
+	reader := bufio.NewReader(conn)
+	if args[0] == "watch" {
+		for {
+			out, err := reader.ReadBytes('\n')
+			if err != nil {
+				if err != io.EOF {
+					fmt.Fprintf(os.Stderr, "Couldn't read the state: %s\n", err)
+				}
+				return 1
+			}
+			fmt.Print(string(out))
+		}
+	}
+
+	out, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Couldn't read the reply: %s\n", err)
+		return 1
+	}
+	fmt.Print(string(out))
+
+	var reply ctlReply
+	err = json.Unmarshal(out, &reply)
+	if err != nil || !reply.Ok {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	busInterface  = "org.nwg.DockHyprland"
+	busObjectPath = dbus.ObjectPath("/org/nwg/DockHyprland")
+)
+
+var (
+	busConn  *dbus.Conn
+	busProps *prop.Properties
+)
+
+// Dock item, as returned by the GetItems method: (id, name, pinned, instances, addresses)
+type busItem struct {
+	ID        string
+	Name      string
+	Pinned    bool
+	Instances int32
+	Addresses []string
+}
+
+// Methods of the org.nwg.DockHyprland interface; they share the control socket command handlers
+type dockService struct{}
+
+func (d dockService) Show() *dbus.Error {
+	return busError(runCtlCommand("show"))
+}
+
+func (d dockService) Hide() *dbus.Error {
+	return busError(runCtlCommand("hide"))
+}
+
+func (d dockService) Toggle() *dbus.Error {
+	return busError(runCtlCommand("toggle"))
+}
+
+// Takes an index as shown on the dock (counting from 1), or an item ID; returns the action taken
+func (d dockService) Activate(item string) (string, *dbus.Error) {
+	reply := runCtlCommand("activate", item)
+	if !reply.Ok {
+		return "", busError(reply)
+	}
+	return reply.Data.(map[string]string)["action"], nil
+}
+
+func (d dockService) Pin(ID string) *dbus.Error {
+	return busError(runCtlCommand("pin", ID))
+}
+
+func (d dockService) Unpin(ID string) *dbus.Error {
+	return busError(runCtlCommand("unpin", ID))
+}
+
+func (d dockService) Reload() *dbus.Error {
+	return busError(runCtlCommand("reload"))
+}
+
+func (d dockService) GetItems() ([]busItem, *dbus.Error) {
+	reply := runCtlCommand("list-items")
+	if !reply.Ok {
+		return nil, busError(reply)
+	}
+	result := []busItem{}
+	for _, info := range reply.Data.([]itemInfo) {
+		result = append(result, busItem{
+			ID:        info.ID,
+			Name:      info.Name,
+			Pinned:    info.Pinned,
+			Instances: int32(info.Instances),
+			Addresses: info.Addresses,
+		})
+	}
+	return result, nil
+}
+
+func busError(reply ctlReply) *dbus.Error {
+	if reply.Ok {
+		return nil
+	}
+	return dbus.NewError(fmt.Sprintf("%s.Error", busInterface), []interface{}{reply.Error})
+}
+
+// Named instances own org.nwg.DockHyprland.<name>
+func busName() string {
+	if *instanceName == "" {
+		return busInterface
+	}
+	name := *instanceName
+	// bus name elements must not start w/ a digit
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return fmt.Sprintf("%s.%s", busInterface, name)
+}
+
+// Connects to the session bus, exports the service and requests the bus name
+func startBusService() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+
+	service := dockService{}
+	err = conn.Export(service, busObjectPath, busInterface)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	props, err := prop.Export(conn, busObjectPath, prop.Map{
+		busInterface: {
+			"Visible":     {Value: false, Writable: false, Emit: prop.EmitTrue},
+			"PinnedItems": {Value: []string{}, Writable: false, Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	node := &introspect.Node{
+		Name: string(busObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       busInterface,
+				Methods:    introspect.Methods(service),
+				Properties: props.Introspection(busInterface),
+				Signals: []introspect.Signal{
+					{Name: "VisibilityChanged", Args: []introspect.Arg{{Name: "visible", Type: "b"}}},
+					{Name: "ItemsChanged"},
+				},
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), busObjectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	reply, err := conn.RequestName(busName(), dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return fmt.Errorf("bus name %s already taken", busName())
+	}
+
+	busConn = conn
+	busProps = props
+	log.Infof("D-Bus service: %s", busName())
+	return nil
+}
+
+func stopBusService() {
+	if busConn != nil {
+		busConn.Close()
+		busConn = nil
+	}
+}
+
+// Called on the dock window show / hide
+func busVisibilityChanged(visible bool) {
+	if busConn == nil {
+		return
+	}
+	busProps.SetMust(busInterface, "Visible", visible)
+	err := busConn.Emit(busObjectPath, fmt.Sprintf("%s.VisibilityChanged", busInterface), visible)
+	if err != nil {
+		log.Warnf("Error emitting VisibilityChanged: %s", err)
+	}
+}
+
+// Called whenever the main box gets rebuilt
+func busItemsChanged() {
+	if busConn == nil {
+		return
+	}
+	pinned := []string{}
+	if d := focusedDock(); d != nil {
+		pinned = append(pinned, d.pinned...)
+	}
+	busProps.SetMust(busInterface, "PinnedItems", pinned)
+	err := busConn.Emit(busObjectPath, fmt.Sprintf("%s.ItemsChanged", busInterface))
+	if err != nil {
+		log.Warnf("Error emitting ItemsChanged: %s", err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Starts a private session bus, and points DBUS_SESSION_BUS_ADDRESS at it for the duration of the test
+func startPrivateBus(t *testing.T) {
+	t.Helper()
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command(path, "--session", "--print-address", "--nofork")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Couldn't read the bus address: %s", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+func waitForSignal(t *testing.T, signals chan *dbus.Signal, name string) *dbus.Signal {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-signals:
+			if s.Name == fmt.Sprintf("%s.%s", busInterface, name) {
+				return s
+			}
+		case <-timeout:
+			t.Fatalf("no %s signal", name)
+			return nil
+		}
+	}
+}
+
+func TestBusService(t *testing.T) {
+	startPrivateBus(t)
+	setFlags(t, map[string]string{"r": "false", "d": "false"})
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"foot.desktop":    "[Desktop Entry]\nName=Foot\n",
+		"firefox.desktop": "[Desktop Entry]\nName=Firefox\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appDirs = []string{dir}
+
+	d := &dock{pinnedFile: filepath.Join(dir, "pinned")}
+	savePinned(d.pinnedFile, []string{"foot"})
+	docks = []*dock{d}
+
+	realInMainLoop := inMainLoop
+	// no GTK here: commands run directly, and main box rebuilds only rebuild the items
+	inMainLoop = func(f func() ctlReply) ctlReply { return f() }
+	refreshMainBox = func(bool) {
+		d.pinned, _ = loadTextFile(d.pinnedFile)
+		d.items = nil
+		for _, ID := range d.pinned {
+			d.items = append(d.items, dockItem{ID: ID, Pinned: true})
+		}
+		d.items = append(d.items, dockItem{ID: "kitty", Instances: []client{{Address: "0x1234"}}})
+		busItemsChanged()
+	}
+	t.Cleanup(func() {
+		stopBusService()
+		inMainLoop = realInMainLoop
+		refreshMainBox = nil
+		docks = nil
+		appDirs = nil
+	})
+	refreshMainBox(true)
+
+	if err := startBusService(); err != nil {
+		t.Fatalf("Couldn't start the service: %s", err)
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.AddMatchSignal(dbus.WithMatchObjectPath(busObjectPath), dbus.WithMatchInterface(busInterface))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	obj := conn.Object(busName(), busObjectPath)
+
+	wantError := func(method string, err error, want string) {
+		t.Helper()
+		if e, ok := err.(dbus.Error); !ok || e.Name != busInterface+".Error" || e.Error() != want {
+			t.Errorf("%s: got %#v, want the %s.Error %q", method, err, busInterface, want)
+		}
+	}
+
+	wantError("Show", obj.Call(busInterface+".Show", 0).Err, "not resident, ignoring")
+
+	// as on the dock window show
+	busVisibilityChanged(true)
+	if s := waitForSignal(t, signals, "VisibilityChanged"); !reflect.DeepEqual(s.Body, []interface{}{true}) {
+		t.Errorf("VisibilityChanged: got %v, want [true]", s.Body)
+	}
+	visible, err := obj.GetProperty(busInterface + ".Visible")
+	if err != nil || visible.Value() != true {
+		t.Errorf("Visible: got %v (%v), want true", visible, err)
+	}
+
+	if err = obj.Call(busInterface+".Pin", 0, "firefox").Err; err != nil {
+		t.Fatalf("Pin: %s", err)
+	}
+	waitForSignal(t, signals, "ItemsChanged")
+	pinned, err := obj.GetProperty(busInterface + ".PinnedItems")
+	if err != nil || !reflect.DeepEqual(pinned.Value(), []string{"foot", "firefox"}) {
+		t.Errorf("PinnedItems after Pin: got %v (%v), want [foot firefox]", pinned, err)
+	}
+	if saved, _ := loadTextFile(d.pinnedFile); !reflect.DeepEqual(saved, []string{"foot", "firefox"}) {
+		t.Errorf("pinned file after Pin: got %q, want [foot firefox]", saved)
+	}
+
+	wantError("Pin of a pinned item", obj.Call(busInterface+".Pin", 0, "firefox").Err, "firefox already pinned")
+
+	var items []busItem
+	if err = obj.Call(busInterface+".GetItems", 0).Store(&items); err != nil {
+		t.Fatalf("GetItems: %s", err)
+	}
+	want := []busItem{
+		{ID: "foot", Name: "Foot", Pinned: true, Addresses: []string{}},
+		{ID: "firefox", Name: "Firefox", Pinned: true, Addresses: []string{}},
+		{ID: "kitty", Name: "kitty", Instances: 1, Addresses: []string{"0x1234"}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("GetItems: got %+v, want %+v", items, want)
+	}
+
+	wantError("Activate", obj.Call(busInterface+".Activate", 0, "9").Err, "no item #9, the dock shows 3")
+
+	if err = obj.Call(busInterface+".Unpin", 0, "foot").Err; err != nil {
+		t.Fatalf("Unpin: %s", err)
+	}
+	waitForSignal(t, signals, "ItemsChanged")
+	pinned, err = obj.GetProperty(busInterface + ".PinnedItems")
+	if err != nil || !reflect.DeepEqual(pinned.Value(), []string{"firefox"}) {
+		t.Errorf("PinnedItems after Unpin: got %v (%v), want [firefox]", pinned, err)
+	}
+	wantError("Unpin of an item not pinned", obj.Call(busInterface+".Unpin", 0, "foot").Err, "foot not pinned")
+
+	docks = nil
+	wantError("Pin w/o a dock", obj.Call(busInterface+".Pin", 0, "foot").Err, "no dock")
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dlasky/gotk3-layershell/layershell"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+// Dock window w/ its items: one per monitor in the multi-monitor mode (-mm), the only one otherwise
+type dock struct {
+	output        string // name of the monitor whose windows we show; empty for all monitors
+	monitor       *gdk.Monitor
+	win           *gtk.Window
+	outerBox      *gtk.Box
+	alignmentBox  *gtk.Box
+	mainBox       *gtk.Box
+	filterLabel   *gtk.Label
+	filterText    string
+	items         []dockItem
+	searchResults []dockItem
+	progressBars  []progressBar
+	pinned        []string
+	pinnedFile    string
+	src           glib.SourceHandle
+	destroyed     bool
+	iconScale     int
+	hovered       bool
+	overlapped    bool // by a window, in the intellihide mode
+	intellihidden bool
+
+	fullscreenHidden        bool
+	visibleBeforeFullscreen bool
+
+	showSrc  glib.SourceHandle
+	animSrc  glib.SourceHandle
+	progress float64 // of the show/hide animation, 1 when shown
+	hiding   bool    // the hiding animation is running
+	menuOpen bool
+}
+
+// Creates the dock window on the monitor, or on the one the compositor chooses, if nil
+func newDock(output string, monitor *gdk.Monitor) *dock {
+	// new docks of plugged in monitors show up, unless overlapped
+	d := &dock{output: output, monitor: monitor, pinnedFile: pinnedFile, intellihidden: *intellihide}
+	if output != "" && *pinnedPerMonitor {
+		d.pinnedFile = fmt.Sprintf("%s-%s", pinnedFile, output)
+		// start w/ the shared items
+		if !pathExists(d.pinnedFile) && pathExists(pinnedFile) {
+			err := copyFile(pinnedFile, d.pinnedFile)
+			if err != nil {
+				log.Warnf("Error copying file: %s", err)
+			}
+		}
+	}
+
+	d.progress = 1
+	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	if err != nil {
+		log.Fatal("Unable to create window:", err)
+	}
+	d.win = win
+
+	layershell.InitForWindow(win)
+	layershell.SetNamespace(win, instanceID("nwg-dock"))
+	if monitor != nil {
+		layershell.SetMonitor(win, monitor)
+	}
+
+	if *exclusive {
+		layershell.AutoExclusiveZoneEnable(win)
+	}
+
+	if *position == "bottom" || *position == "top" {
+		if *position == "bottom" {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, true)
+		} else {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, true)
+		}
+		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, *full)
+		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_RIGHT, *full)
+	}
+
+	if *position == "left" || *position == "right" {
+		if *position == "left" {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, true)
+		} else {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_RIGHT, true)
+		}
+		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, *full)
+		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *full)
+	}
+
+	if *layer == "top" {
+		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_TOP)
+	} else if *layer == "bottom" {
+		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_BOTTOM)
+	} else {
+		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_OVERLAY)
+		// w/ -ra the default zone of 0 lets the compositor keep the dock off the space others reserve
+		if !*reservedAreas {
+			layershell.SetExclusiveZone(win, -1)
+		}
+	}
+
+	d.applyMargins()
+
+	win.Connect("destroy", func() {
+		// docks of unplugged monitors get destroyed, too
+		if !d.destroyed {
+			gtk.MainQuit()
+		}
+	})
+
+	// Close the window on leave, but not immediately, to avoid accidental closes
+	win.Connect("leave-notify-event", func(w *gtk.Window, e *gdk.Event) {
+		// we don't leave the window by hovering a button
+		if gdk.EventCrossingNewFromEvent(e).Detail() != gdk.NOTIFY_INFERIOR {
+			d.hovered = false
+		}
+		d.scheduleClose()
+	})
+
+	win.Connect("enter-notify-event", func() {
+		d.hovered = true
+		d.cancelClose()
+	})
+
+	// moved to an output w/ another scale, or the scale changed at runtime
+	win.Connect("notify::scale-factor", d.rescaleIcons)
+
+	win.Connect("show", func() {
+		d.intellihidden = false
+		busVisibilityChanged(true)
+		broadcastState()
+	})
+
+	win.Connect("hide", func() {
+		d.hovered = false
+		if keyboardDock == d {
+			leaveKeyboardMode()
+		}
+		busVisibilityChanged(docksVisible())
+		broadcastState()
+	})
+
+	d.outerBox, _ = gtk.BoxNew(outerOrientation, 0)
+	_ = d.outerBox.SetProperty("name", "box")
+	win.Add(d.outerBox)
+
+	d.alignmentBox, _ = gtk.BoxNew(innerOrientation, 0)
+	d.outerBox.PackStart(d.alignmentBox, true, true, 0)
+	d.setupKeyboard(d.outerBox)
+	// We'll pack mainBox later, in buildMainBox
+
+	return d
+}
+
+// The -m* argument for the edge
+func (d *dock) margin(edge layershell.LayerShellEdgeFlags) int {
+	margin := *marginBottom
+	switch edge {
+	case layershell.LAYER_SHELL_EDGE_TOP:
+		margin = *marginTop
+	case layershell.LAYER_SHELL_EDGE_LEFT:
+		margin = *marginLeft
+	case layershell.LAYER_SHELL_EDGE_RIGHT:
+		margin = *marginRight
+	}
+	return margin
+}
+
+func (d *dock) applyMargins() {
+	for _, edge := range reservedEdges {
+		layershell.SetMargin(d.win, edge, d.margin(edge))
+	}
+	// the slide animation may be moving the dock
+	if *animation == "slide" {
+		d.setProgress(d.progress)
+	}
+}
+
+/*
+Window on-leave-notify event hides the dock with glib Timeout, after the -cd delay.
+We might have left the window by accident, so let's clear the timeout if window re-entered.
+Furthermore - hovering a button triggers window on-leave-notify event, and the timeout
+needs to be cleared as well.
+*/
+func (d *dock) cancelClose() {
+	if d.src > 0 {
+		glib.SourceRemove(d.src)
+		d.src = 0
+	}
+}
+
+// Moves the dock to the monitor, or lets the compositor choose, if nil; a mapped window gets remapped
+func (d *dock) setMonitor(monitor *gdk.Monitor) {
+	if sameMonitor(d.monitor, monitor) {
+		return
+	}
+	d.monitor = monitor
+	layershell.SetMonitor(d.win, monitor)
+	d.rescaleIcons()
+	if layoutWatched() {
+		scheduleLayoutCheck()
+	}
+}
+
+// Scale factor of the dock's monitor, or of the output the compositor placed the dock on
+func (d *dock) scale() int {
+	scale := d.win.GetScaleFactor()
+	if d.monitor != nil {
+		scale = d.monitor.GetScaleFactor()
+	}
+	return max(scale, 1)
+}
+
+func (d *dock) rescaleIcons() {
+	if d.mainBox != nil && d.scale() != d.iconScale {
+		log.WithField("output", d.output).Debugf("Scale %v -> %v, re-rendering icons", d.iconScale, d.scale())
+		d.buildMainBox()
+	}
+}
+
+// Removes the dock of an unplugged monitor
+func (d *dock) destroy() {
+	if keyboardDock == d {
+		leaveKeyboardMode()
+	}
+	d.cancelClose()
+	d.destroyed = true
+	d.win.Destroy()
+}
+
+// Hyprland id of the dock's monitor, -1 if not bound to a monitor, or the monitor is gone
+func (d *dock) monitorID() int {
+	if d.output == "" {
+		return -1
+	}
+	for _, m := range monitors {
+		if m.Name == d.output {
+			return m.Id
+		}
+	}
+	return -1
+}
+
+// Clients the dock shows: the ones on its monitor in the multi-monitor mode, all of them otherwise
+func (d *dock) monitorClients() []client {
+	if d.output == "" {
+		return clients
+	}
+	id := d.monitorID()
+	var result []client
+	for _, c := range clients {
+		if c.Monitor == id {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (d *dock) showsClient(address string) bool {
+	for _, c := range d.monitorClients() {
+		if c.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dock) isPinned(ID string) bool {
+	return inPinned(d.pinned, ID)
+}
+
+// Re-reads the pinned items file before changing it, as other docks may share it
+func (d *dock) pin(ID string) {
+	d.pinned, _ = loadTextFile(d.pinnedFile)
+	if d.isPinned(ID) {
+		log.WithField("item", ID).Warn("Already pinned")
+		return
+	}
+	d.pinned = append(d.pinned, ID)
+	savePinned(d.pinnedFile, d.pinned)
+}
+
+func (d *dock) unpin(ID string) {
+	d.pinned, _ = loadTextFile(d.pinnedFile)
+	d.pinned = remove(d.pinned, ID)
+	savePinned(d.pinnedFile, d.pinned)
+}
+
+// The dock on the focused monitor; the only one, unless in the multi-monitor mode
+func focusedDock() *dock {
+	for _, d := range docks {
+		if d.output == focusedOutput {
+			return d
+		}
+	}
+	if len(docks) > 0 {
+		return docks[0]
+	}
+	return nil
+}
+
+func docksVisible() bool {
+	for _, d := range docks {
+		if d.isShown() {
+			return true
+		}
+	}
+	return false
+}
+
+func showDocks() {
+	for _, d := range docks {
+		d.show()
+	}
+}
+
+func hideDocks() {
+	for _, d := range docks {
+		d.hide()
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Hides docks while a fullscreen window is on the active workspace of their monitor, and restores their
+previous visibility once it's gone. Apps w/ the `fullscreenDock` rule keep the dock as it is.
+*/
+func updateFullscreen(mons []monitor, wss []workspace, wins []client) {
+	for _, d := range docks {
+		c := d.fullscreenClient(mons, wss, wins)
+		hide := c != nil
+		if c != nil {
+			if r := classRule(c.Class); r != nil && r.FullscreenDock {
+				hide = false
+			}
+		}
+
+		if hide && !d.fullscreenHidden {
+			log.WithFields(log.Fields{"output": d.output, "class": c.Class}).Debug("Fullscreen window, hiding the dock")
+			d.fullscreenHidden = true
+			d.visibleBeforeFullscreen = d.isShown()
+			d.hide()
+		} else if !hide && d.fullscreenHidden {
+			log.WithField("output", d.output).Debug("No fullscreen window, restoring the dock")
+			d.fullscreenHidden = false
+			if d.visibleBeforeFullscreen {
+				d.show()
+			}
+		}
+	}
+}
+
+// The fullscreen (not just maximized) client on the active workspace of the dock's monitor, if any
+func (d *dock) fullscreenClient(mons []monitor, wss []workspace, wins []client) *client {
+	m := d.hyprMonitor(mons)
+	if m == nil {
+		return nil
+	}
+	hasFullscreen := false
+	for _, ws := range wss {
+		if ws.Id == m.ActiveWorkspace.Id {
+			hasFullscreen = ws.Hasfullscreen
+		}
+	}
+	if !hasFullscreen {
+		return nil
+	}
+	for i, c := range wins {
+		// 1: maximized, 2: fullscreen, 3: both
+		if c.Workspace.Id == m.ActiveWorkspace.Id && c.Fullscreen&2 != 0 {
+			return &wins[i]
+		}
+	}
+	return nil
+}
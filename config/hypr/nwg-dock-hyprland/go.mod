@@ -4,7 +4,9 @@ go 1.23
 
 require (
 	github.com/allan-simon/go-singleinstance v0.0.0-20210120080615-d0997106ab37
+	github.com/coreos/go-systemd/v22 v22.7.0
 	github.com/dlasky/gotk3-layershell v0.0.0-20240515133811-5c5115f0d774
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/gotk3/gotk3 v0.6.5-0.20240618185848-ff349ae13f56
 	github.com/sirupsen/logrus v1.9.3
 )
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dlasky/gotk3-layershell/layershell"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+var hotspotPlaces = []string{"dock", "center", "corners"}
+
+// -hr pushes against the edge need to come within this time [ms]
+const pressureWindow = 1000
+
+// Invisible window at the screen edge, that shows the dock when hovered
+type hotspot struct {
+	d           *dock
+	win         *gtk.Window
+	detectorBox *gtk.EventBox // nil if the hotspot delay check is off
+	hotspotBox  *gtk.EventBox
+	corner      bool
+	pushes      int   // against the edge so far, w/ -hr
+	firstPushAt int64 // [ms]
+}
+
+var hotspots []*hotspot
+
+// (Re)creates hot spot windows in the autohide and intellihide modes: on the dock's monitor(s), or on all of them
+func setupHotSpots() {
+	for _, h := range hotspots {
+		h.win.Destroy()
+	}
+	hotspots = nil
+	if !*autohide && !*intellihide {
+		return
+	}
+
+	mRefProvider, _ := gtk.CssProviderNew()
+	css := "window { background-color: rgba (0, 0, 0, 0); border: none}"
+	err := mRefProvider.LoadFromData(css)
+	if err != nil {
+		log.Warn(err)
+	}
+
+	if *multiMonitor {
+		// each dock w/ its own hot spot
+		for _, d := range docks {
+			if mon, err := outputMonitor(output2mon, d.output); err == nil {
+				hotspots = append(hotspots, newHotSpots(*mon, d)...)
+			}
+		}
+	} else if *targetOutput == "" {
+		// hot spots on all displays
+		gdkMonitors, _ := listGdkMonitors()
+		for _, monitor := range gdkMonitors {
+			hotspots = append(hotspots, newHotSpots(monitor, docks[0])...)
+		}
+	} else if monitor, err := outputMonitor(output2mon, *targetOutput); err == nil {
+		// hot spot on the selected display only
+		hotspots = append(hotspots, newHotSpots(*monitor, docks[0])...)
+	} else {
+		log.Warnf("No hot spot: %s", err)
+	}
+
+	for _, h := range hotspots {
+		ctx, _ := h.win.GetStyleContext()
+		ctx.AddProvider(mRefProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+		h.win.ShowAll()
+	}
+}
+
+// One hot spot where the dock is, or in the center of the edge, or two in its corners, as set w/ -hp
+func newHotSpots(monitor gdk.Monitor, d *dock) []*hotspot {
+	if *hotspotPlace != "corners" {
+		return []*hotspot{newHotSpot(monitor, d, nil)}
+	}
+	start, end := layershell.LAYER_SHELL_EDGE_LEFT, layershell.LAYER_SHELL_EDGE_RIGHT
+	if *position == "left" || *position == "right" {
+		start, end = layershell.LAYER_SHELL_EDGE_TOP, layershell.LAYER_SHELL_EDGE_BOTTOM
+	}
+	return []*hotspot{newHotSpot(monitor, d, &start), newHotSpot(monitor, d, &end)}
+}
+
+// The hot spot is anchored to the corner at the given side of the dock's edge, if not nil
+func newHotSpot(monitor gdk.Monitor, d *dock, corner *layershell.LayerShellEdgeFlags) *hotspot {
+	h := &hotspot{d: d, corner: corner != nil}
+	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	h.win = win
+
+	layershell.InitForWindow(win)
+	layershell.SetMonitor(win, &monitor)
+	layershell.SetNamespace(win, instanceID("nwg-dock-hotspot"))
+
+	var box *gtk.Box
+	if *position == "bottom" || *position == "top" {
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	} else {
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+	}
+	win.Add(box)
+
+	// No need to measure the pointer speed w/o the hotspot delay; the detector would only block clicks
+	if *hotspotDelay > 0 {
+		h.detectorBox, _ = gtk.EventBoxNew()
+		_ = h.detectorBox.SetProperty("name", "detector-box")
+
+		// the hotspot needs to end up at the screen edge, next to the detector
+		if *position == "bottom" || *position == "right" {
+			box.PackStart(h.detectorBox, false, false, 0)
+		} else {
+			box.PackEnd(h.detectorBox, false, false, 0)
+		}
+
+		h.detectorBox.Connect("enter-notify-event", func() {
+			detectorEnteredAt = time.Now().UnixNano() / 1000000
+		})
+	}
+
+	h.hotspotBox, _ = gtk.EventBoxNew()
+	_ = h.hotspotBox.SetProperty("name", "hotspot-box")
+
+	if *position == "bottom" || *position == "right" {
+		box.PackStart(h.hotspotBox, false, false, 0)
+	} else {
+		box.PackEnd(h.hotspotBox, false, false, 0)
+	}
+
+	h.hotspotBox.Connect("enter-notify-event", func() {
+		if d.fullscreenHidden {
+			log.Debug("Fullscreen window, don't show the window")
+			return
+		}
+		hotspotEnteredAt := time.Now().UnixNano() / 1000000
+		delay := hotspotEnteredAt - detectorEnteredAt
+		d.setMonitor(&monitor)
+		if delay <= *hotspotDelay || *hotspotDelay == 0 {
+			if !h.pushed(hotspotEnteredAt) {
+				log.Debugf("Push %v of %v, don't show the window yet", h.pushes, *hotspotPressure)
+				return
+			}
+			log.Debugf("Delay %v < %v ms, let's show the window!", delay, *hotspotDelay)
+			d.scheduleShow()
+		} else {
+			log.Debugf("Delay %v > %v ms, don't show the window :/", delay, *hotspotDelay)
+		}
+	})
+
+	// passing by the hot spot quickly, before the -sd delay passes, doesn't show the dock
+	h.hotspotBox.Connect("leave-notify-event", d.cancelShow)
+
+	edge, _ := d.edgeMargin()
+	layershell.SetAnchor(win, edge, true)
+	if corner != nil {
+		layershell.SetAnchor(win, *corner, true)
+	} else if *hotspotPlace == "dock" {
+		if *position == "bottom" || *position == "top" {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, *full)
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_RIGHT, *full)
+		} else {
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, *full)
+			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *full)
+		}
+	}
+
+	layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_OVERLAY)
+
+	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_TOP, *marginTop)
+	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_LEFT, *marginLeft)
+	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_RIGHT, *marginRight)
+	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *marginBottom)
+
+	layershell.SetExclusiveZone(win, -1)
+
+	h.resize()
+	return h
+}
+
+// Counts pushes against the edge; returns true once there's been -hr of them within the pressure window
+func (h *hotspot) pushed(at int64) bool {
+	if at-h.firstPushAt > pressureWindow {
+		h.firstPushAt, h.pushes = at, 0
+	}
+	h.pushes++
+	if h.pushes < *hotspotPressure {
+		return false
+	}
+	h.firstPushAt, h.pushes = 0, 0
+	return true
+}
+
+/*
+Sizes the hot spot after the dock: as long as the dock (unless -hl given), -ht thick, w/ the detector
+a third of the dock's thickness. We take the dock's preferred size, as it may be hidden, and not allocated yet.
+*/
+func (h *hotspot) resize() {
+	_, dockWidth := h.d.win.GetPreferredWidth()
+	_, dockHeight := h.d.win.GetPreferredHeight()
+	length, thickness := dockWidth, dockHeight
+	if *position == "left" || *position == "right" {
+		length, thickness = dockHeight, dockWidth
+	}
+	if *hotspotLength > 0 {
+		length = *hotspotLength
+	} else if h.corner {
+		length = *imgSize
+	}
+
+	size := func(box *gtk.EventBox, t int) {
+		if *position == "bottom" || *position == "top" {
+			box.SetSizeRequest(length, t)
+		} else {
+			box.SetSizeRequest(t, length)
+		}
+	}
+	if h.detectorBox != nil {
+		size(h.detectorBox, thickness/3)
+	}
+	size(h.hotspotBox, *hotspotThickness)
+	// shrink, if the dock did
+	h.win.Resize(1, 1)
+}
+
+func (d *dock) resizeHotSpots() {
+	for _, h := range hotspots {
+		if h.d == d {
+			h.resize()
+		}
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
 )
 
 type workspace struct {
@@ -32,6 +35,10 @@ type monitor struct {
 		Id   int    `json:"id"`
 		Name string `json:"name"`
 	} `json:"activeWorkspace"`
+	SpecialWorkspace struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"specialWorkspace"`
 	Reserved   []int   `json:"reserved"`
 	Scale      float64 `json:"scale"`
 	Transform  int     `json:"transform"`
@@ -90,6 +97,32 @@ func hyprctl(cmd string) ([]byte, error) {
 	return reply[:n], nil
 }
 
+// Runs the dispatcher, and logs it along w/ the client it concerns, if any
+func hyprDispatch(dispatcher string, c *client) ([]byte, error) {
+	cmd := fmt.Sprintf("dispatch %s", dispatcher)
+	reply, err := hyprctl(cmd)
+
+	entry := log.WithFields(log.Fields{"command": cmd, "reply": strings.TrimSpace(string(reply))})
+	if c != nil {
+		entry = entry.WithFields(log.Fields{"class": c.Class, "address": c.Address})
+	}
+	if err != nil {
+		entry.WithError(err).Warn("Dispatch failed")
+	} else {
+		entry.Info("Dispatch")
+	}
+	return reply, err
+}
+
+// Decodes the reply into v, w/o touching the globals
+func hyprctlJSON(cmd string, v interface{}) error {
+	reply, err := hyprctl(cmd)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(reply, v)
+}
+
 func listMonitors() error {
 	reply, err := hyprctl("j/monitors")
 	if err != nil {
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+Pinned items sources the `import` subcommand understands, w/ their default locations:
+
+nwg-dock: ~/.cache/nwg-dock-pinned, one app_id per line; ~/.config/nwg-dock/style.css is imported as well, if we have none;
+plank:    ~/.config/plank/dock1/launchers/*.dockitem, w/ the `Launcher=file:///path/to/app.desktop` line;
+latte:    ~/.config/latte/*.layout.latte, w/ the `launchers=applications:app.desktop,...` line;
+kde:      ~/.config/plasma-org.kde.plasma.desktop-appletsrc, as above.
+*/
+var importSources = []string{"nwg-dock", "plank", "latte", "kde"}
+
+var launchersLine = regexp.MustCompile(`^launchers\d*=`)
+
+// Imports pinned items from another dock into our pinned items file. Returns the exit code.
+func importPinned(args []string) int {
+	if len(args) < 1 || !isIn(importSources, args[0]) {
+		fmt.Fprintf(os.Stderr, "Usage: nwg-dock-hyprland import [arguments] <%s> [path]\n",
+			strings.Join(importSources, "|"))
+		return 2
+	}
+	source := args[0]
+
+	if cacheDir() == "" {
+		fmt.Fprintln(os.Stderr, "Couldn't determine cache directory location")
+		return 1
+	}
+	pinnedFile = filepath.Join(cacheDir(), instanceID("nwg-dock-pinned"))
+
+	home := os.Getenv("HOME")
+	var paths []string
+	if len(args) > 1 {
+		paths = args[1:]
+	} else {
+		switch source {
+		case "nwg-dock":
+			paths = []string{filepath.Join(cacheDir(), "nwg-dock-pinned")}
+		case "plank":
+			paths, _ = filepath.Glob(filepath.Join(home, ".config/plank/dock1/launchers/*.dockitem"))
+		case "latte":
+			paths, _ = filepath.Glob(filepath.Join(home, ".config/latte/*.layout.latte"))
+		case "kde":
+			paths = []string{filepath.Join(configHome(), "plasma-org.kde.plasma.desktop-appletsrc")}
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "No %s files found\n", source)
+		return 1
+	}
+	for _, path := range paths {
+		if filepath.Clean(path) == pinnedFile {
+			// nwg-dock for sway and the unnamed instance share the file
+			fmt.Fprintf(os.Stderr, "%s is the pinned items file of this dock already, nothing to import; "+
+				"use -name to import into a named instance\n", pinnedFile)
+			if source == "nwg-dock" {
+				importNwgDockCss()
+			}
+			return 1
+		}
+	}
+
+	var entries []string
+	for _, path := range paths {
+		lines, err := loadTextFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't read %s: %s\n", path, err)
+			return 1
+		}
+		for _, line := range lines {
+			switch source {
+			case "nwg-dock":
+				entries = append(entries, line)
+			case "plank":
+				if strings.HasPrefix(line, "Launcher=") {
+					entries = append(entries, strings.TrimPrefix(line, "Launcher="))
+				}
+			case "latte", "kde":
+				if launchersLine.MatchString(line) {
+					_, value, _ := strings.Cut(line, "=")
+					for _, e := range strings.Split(value, ",") {
+						if e != "" {
+							entries = append(entries, e)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	appDirs = getAppDirs()
+	rules, _ = loadRules(filepath.Join(configDir(), *rulesFileName))
+	pinned, _ := loadTextFile(pinnedFile)
+
+	var added, unresolved []string
+	for _, entry := range entries {
+		ID, ok := resolveDesktopID(entry)
+		if !ok {
+			unresolved = append(unresolved, entry)
+			continue
+		}
+		if !inPinned(pinned, ID) {
+			pinned = append(pinned, ID)
+			added = append(added, ID)
+		}
+	}
+
+	if len(added) > 0 {
+		savePinned(pinnedFile, pinned)
+	}
+	for _, ID := range added {
+		fmt.Printf("pinned: %s\n", ID)
+	}
+	for _, entry := range unresolved {
+		fmt.Fprintf(os.Stderr, "unresolved: %s\n", entry)
+	}
+	fmt.Printf("%v item(s) pinned, %v unresolved, pinned items file: %s\n", len(added), len(unresolved), pinnedFile)
+
+	if source == "nwg-dock" {
+		importNwgDockCss()
+	}
+
+	return 0
+}
+
+// Copies the style sheet of nwg-dock for sway, if we have none
+func importNwgDockCss() {
+	swayCss := filepath.Join(configHome(), "nwg-dock/style.css")
+	ourCss := filepath.Join(configDir(), effectiveCssFileName())
+	if pathExists(swayCss) && !pathExists(ourCss) {
+		createDir(configDir())
+		err := copyFile(swayCss, ourCss)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't copy %s: %s\n", swayCss, err)
+		}
+	}
+}
+
+/*
+Returns the desktop ID (.desktop file name w/o extension) for an entry of another dock's launchers list, which may be:
+a desktop ID, "app.desktop", "applications:app.desktop", "file:///path/to/app.desktop", or an app_id / class name.
+*/
+func resolveDesktopID(entry string) (string, bool) {
+	entry = strings.TrimSpace(entry)
+	entry = strings.TrimPrefix(entry, "applications:")
+	if strings.HasPrefix(entry, "file://") {
+		u, err := url.Parse(entry)
+		if err != nil {
+			return "", false
+		}
+		entry = u.Path
+	}
+	if strings.Contains(entry, "://") {
+		// e.g. KDE "preferred://browser"
+		return "", false
+	}
+
+	if strings.HasPrefix(entry, "/") {
+		if !pathExists(entry) || !strings.HasSuffix(entry, ".desktop") {
+			return "", false
+		}
+		return strings.TrimSuffix(filepath.Base(entry), ".desktop"), true
+	}
+
+	ID := strings.TrimSuffix(entry, ".desktop")
+	if ID == "" {
+		return "", false
+	}
+	if r := ruleFor(ID); r != nil && r.Alias != "" {
+		return r.Alias, true
+	}
+	for _, d := range appDirs {
+		if pathExists(filepath.Join(d, fmt.Sprintf("%s.desktop", ID))) {
+			return ID, true
+		}
+		if pathExists(filepath.Join(d, fmt.Sprintf("%s.desktop", strings.ToLower(ID)))) {
+			return strings.ToLower(ID), true
+		}
+	}
+
+	if p := searchDesktopDirs(ID); p != "" {
+		return strings.TrimSuffix(filepath.Base(p), ".desktop"), true
+	}
+	return "", false
+}
+
+func configHome() string {
+	if os.Getenv("XDG_CONFIG_HOME") != "" {
+		return os.Getenv("XDG_CONFIG_HOME")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config")
+}
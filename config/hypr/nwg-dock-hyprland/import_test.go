@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveDesktopID(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"foot.desktop", "firefox.desktop", "org.gnome.Nautilus.desktop"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("[Desktop Entry]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appDirs = []string{dir}
+	defer func() { appDirs = nil }()
+
+	var err error
+	rules, _, err = parseRules([]byte(`[{"class": "^Code$", "alias": "code-oss"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { rules = nil }()
+
+	tests := []struct {
+		entry string
+		ID    string
+		ok    bool
+	}{
+		{"foot", "foot", true},
+		{" foot.desktop ", "foot", true},
+		{"applications:firefox.desktop", "firefox", true},
+		{"Firefox", "firefox", true},
+		{"file://" + filepath.Join(dir, "foot.desktop"), "foot", true},
+		{filepath.Join(dir, "firefox.desktop"), "firefox", true},
+		{filepath.Join(dir, "missing.desktop"), "", false},
+		{"Nautilus", "org.gnome.Nautilus", true},
+		{"Code", "code-oss", true},
+		{"preferred://browser", "", false},
+		{"applications:", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			ID, ok := resolveDesktopID(tt.entry)
+			if ID != tt.ID || ok != tt.ok {
+				t.Errorf("resolveDesktopID(%q) = %q, %v; want %q, %v", tt.entry, ID, ok, tt.ID, tt.ok)
+			}
+		})
+	}
+}
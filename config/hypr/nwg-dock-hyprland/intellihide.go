@@ -0,0 +1,105 @@
+package main
+
+import (
+	"github.com/dlasky/gotk3-layershell/layershell"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rectangle in the layout coordinates, as in Hyprland `at` and `size`
+type rect struct {
+	x, y, w, h int
+}
+
+func (r rect) intersects(o rect) bool {
+	return r.x < o.x+o.w && o.x < r.x+r.w && r.y < o.y+o.h && o.y < r.y+r.h
+}
+
+/*
+Hides docks overlapped by a window on the active workspace of their monitor, and shows them again once
+the overlap clears. We only show docks we've hidden ourselves: the ones hidden w/ `ctl hide` stay hidden.
+*/
+func updateIntellihide(mons []monitor, wins []client) {
+	for _, d := range docks {
+		if d.fullscreenHidden {
+			continue
+		}
+		d.overlapped = d.overlapsWindow(mons, wins)
+		if d.overlapped && d.isShown() && !d.hovered && keyboardDock != d {
+			log.WithField("output", d.output).Debug("Intellihide: window overlaps the dock, hiding")
+			d.intellihide()
+		} else if !d.overlapped && d.intellihidden {
+			log.WithField("output", d.output).Debug("Intellihide: overlap cleared, showing the dock")
+			d.intellihidden = false
+			d.show()
+		}
+	}
+}
+
+func (d *dock) intellihide() {
+	d.hide()
+	d.intellihidden = true
+}
+
+func (d *dock) overlapsWindow(mons []monitor, wins []client) bool {
+	m := d.hyprMonitor(mons)
+	if m == nil {
+		return false
+	}
+	dockRect := d.rect(*m)
+	for _, c := range wins {
+		// an open special workspace covers the active one; its id is 0 if none open
+		onScreen := c.Workspace.Id == m.ActiveWorkspace.Id ||
+			(m.SpecialWorkspace.Id != 0 && c.Workspace.Id == m.SpecialWorkspace.Id)
+		if !onScreen || !c.Mapped || c.Hidden || len(c.At) < 2 || len(c.Size) < 2 {
+			continue
+		}
+		if dockRect.intersects(rect{c.At[0], c.At[1], c.Size[0], c.Size[1]}) {
+			return true
+		}
+	}
+	return false
+}
+
+// The monitor the dock is on; the focused one, if the compositor chooses
+func (d *dock) hyprMonitor(mons []monitor) *monitor {
+	for i, m := range mons {
+		if d.output != "" && m.Name == d.output {
+			return &mons[i]
+		}
+		if d.output == "" && d.monitor != nil && sameMonitor(output2mon[m.Name], d.monitor) {
+			return &mons[i]
+		}
+	}
+	if d.output != "" {
+		return nil
+	}
+	for i, m := range mons {
+		if m.Focused {
+			return &mons[i]
+		}
+	}
+	return nil
+}
+
+// Where the dock is, or would be if shown, on the monitor
+func (d *dock) rect(m monitor) rect {
+	width, height := logicalSize(m)
+	w, h := d.win.GetSize()
+	r := rect{x: m.X + (width-w)/2, y: m.Y + (height-h)/2, w: w, h: h}
+	switch *position {
+	case "bottom":
+		r.y = m.Y + height - h - d.offset(m, layershell.LAYER_SHELL_EDGE_BOTTOM)
+	case "top":
+		r.y = m.Y + d.offset(m, layershell.LAYER_SHELL_EDGE_TOP)
+	case "left":
+		r.x = m.X + d.offset(m, layershell.LAYER_SHELL_EDGE_LEFT)
+	case "right":
+		r.x = m.X + width - w - d.offset(m, layershell.LAYER_SHELL_EDGE_RIGHT)
+	}
+	return r
+}
+
+// Distance of the dock from the monitor edge: the margin, after the space reserved by others, if we avoid it
+func (d *dock) offset(m monitor, edge layershell.LayerShellEdgeFlags) int {
+	return d.margin(edge) + reservedAt(m, edge)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRectIntersects(t *testing.T) {
+	dock := rect{x: 100, y: 1000, w: 400, h: 80}
+	tests := []struct {
+		name string
+		o    rect
+		want bool
+	}{
+		{"overlapping", rect{x: 0, y: 0, w: 1920, h: 1040}, true},
+		{"inside", rect{x: 150, y: 1010, w: 10, h: 10}, true},
+		{"containing", rect{x: 0, y: 0, w: 1920, h: 1080}, true},
+		{"above, touching", rect{x: 0, y: 0, w: 1920, h: 1000}, false},
+		{"left, touching", rect{x: 0, y: 1000, w: 100, h: 80}, false},
+		{"right, touching", rect{x: 500, y: 1000, w: 100, h: 80}, false},
+		{"beside", rect{x: 600, y: 900, w: 300, h: 180}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dock.intersects(tt.o); got != tt.want {
+				t.Errorf("%+v.intersects(%+v) = %v, want %v", dock, tt.o, got, tt.want)
+			}
+			if got := tt.o.intersects(dock); got != tt.want {
+				t.Errorf("%+v.intersects(%+v) = %v, want %v", tt.o, dock, got, tt.want)
+			}
+		})
+	}
+}
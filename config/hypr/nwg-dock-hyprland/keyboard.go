@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/dlasky/gotk3-layershell/layershell"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+// How many apps, that are not on the dock, to show while filtering
+const maxSearchResults = 5
+
+// Visible .desktop file, as found in appDirs
+type desktopEntry struct {
+	ID   string
+	Name string
+}
+
+var (
+	desktopIndex []desktopEntry
+	keyboardDock *dock
+	// whether the dock was shown before entering the keyboard mode, to be left as found
+	keyboardWasShown bool
+)
+
+// Packs the label showing the typed filter, and handles keys while the dock has the keyboard focus
+func (d *dock) setupKeyboard(outerBox *gtk.Box) {
+	d.filterLabel, _ = gtk.LabelNew("")
+	_ = d.filterLabel.SetProperty("name", "filter")
+	d.filterLabel.SetNoShowAll(true)
+	outerBox.PackEnd(d.filterLabel, false, false, 0)
+
+	d.win.Connect("key-press-event", func(w *gtk.Window, e *gdk.Event) bool {
+		return keyboardDock == d && d.handleKeyPress(e)
+	})
+}
+
+// Takes the keyboard focus on demand, until Escape pressed, an item activated, or the dock hidden
+func (d *dock) enterKeyboardMode() {
+	if keyboardDock != d {
+		leaveKeyboardMode()
+		keyboardWasShown = d.isShown()
+		// re-read on every entry, to offer apps installed meanwhile
+		desktopIndex = nil
+	}
+	keyboardDock = d
+	layershell.SetKeyboardMode(d.win, layershell.LAYER_SHELL_KEYBOARD_MODE_ON_DEMAND)
+	ctx, _ := d.win.GetStyleContext()
+	ctx.AddClass("keyboard")
+
+	d.cancelClose()
+	d.show()
+	d.win.Present()
+	d.focusFirstItem()
+}
+
+func leaveKeyboardMode() {
+	d := keyboardDock
+	if d == nil {
+		return
+	}
+	keyboardDock = nil
+	layershell.SetKeyboardMode(d.win, layershell.LAYER_SHELL_KEYBOARD_MODE_NONE)
+	ctx, _ := d.win.GetStyleContext()
+	ctx.RemoveClass("keyboard")
+
+	if d.filterText != "" {
+		d.setFilter("")
+	}
+	if d.overlapped {
+		d.intellihide()
+	} else if !keyboardWasShown && (*autohide || *resident && !*intellihide) {
+		d.hide()
+	}
+}
+
+/*
+Arrows move the focus between buttons, and Enter activates the focused one (GTK does it for us).
+Digits 1-9 activate the Nth item, printable characters filter items, Escape clears the filter,
+and then leaves the keyboard mode.
+*/
+func (d *dock) handleKeyPress(e *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(e)
+	keyVal := keyEvent.KeyVal()
+	state := keyEvent.State()
+	super := state&uint(gdk.SUPER_MASK|gdk.MOD4_MASK) != 0
+
+	switch {
+	case keyVal == gdk.KEY_Escape:
+		if d.filterText != "" {
+			d.setFilter("")
+		} else {
+			leaveKeyboardMode()
+		}
+		return true
+	case keyVal == gdk.KEY_BackSpace:
+		if d.filterText != "" {
+			runes := []rune(d.filterText)
+			d.setFilter(string(runes[:len(runes)-1]))
+		}
+		return true
+	case (keyVal == gdk.KEY_Return || keyVal == gdk.KEY_KP_Enter) && d.filterText != "":
+		d.activateFirstMatch()
+		return true
+	case keyVal >= gdk.KEY_1 && keyVal <= gdk.KEY_9 && (d.filterText == "" || super):
+		d.activateIndex(int(keyVal-gdk.KEY_1) + 1)
+		return true
+	case keyVal >= gdk.KEY_KP_1 && keyVal <= gdk.KEY_KP_1+8 && (d.filterText == "" || super):
+		d.activateIndex(int(keyVal-gdk.KEY_KP_1) + 1)
+		return true
+	}
+
+	if state&uint(gdk.CONTROL_MASK|gdk.MOD1_MASK|gdk.SUPER_MASK|gdk.MOD4_MASK) != 0 {
+		return false
+	}
+	// Space is left to GTK, to activate the focused button
+	if r := gdk.KeyvalToUnicode(keyVal); r > ' ' && unicode.IsPrint(r) {
+		d.setFilter(d.filterText + string(r))
+		return true
+	}
+	return false
+}
+
+func (d *dock) setFilter(text string) {
+	d.filterText = text
+	d.applyFilter()
+	d.focusFirstItem()
+}
+
+/*
+Hides dock items that don't match the filter, and appends buttons of matching apps from the desktop-file index.
+Called at the end of buildMainBox, so that the filter survives the main box being rebuilt.
+*/
+func (d *dock) applyFilter() {
+	for _, result := range d.searchResults {
+		result.box.Destroy()
+	}
+	d.searchResults = nil
+
+	d.filterLabel.SetText(d.filterText)
+	d.filterLabel.SetVisible(d.filterText != "")
+
+	for _, item := range d.items {
+		item.box.SetVisible(d.filterText == "" || matchesFilter(d.filterText, item.ID, getName(item.ID)))
+	}
+	if d.filterText == "" {
+		return
+	}
+
+	if desktopIndex == nil {
+		desktopIndex = loadDesktopIndex()
+	}
+	for _, entry := range desktopIndex {
+		if len(d.searchResults) == maxSearchResults {
+			break
+		}
+		if d.onDock(entry.ID) || !matchesFilter(d.filterText, entry.ID, entry.Name) {
+			continue
+		}
+		box, button := d.pinnedButton(entry.ID)
+		ctx, _ := box.GetStyleContext()
+		ctx.AddClass("search-result")
+		d.mainBox.PackStart(box, false, false, 0)
+		box.ShowAll()
+		d.searchResults = append(d.searchResults, dockItem{ID: entry.ID, box: box, button: button})
+	}
+}
+
+func matchesFilter(filter, ID, name string) bool {
+	filter = strings.ToLower(filter)
+	return strings.Contains(strings.ToLower(ID), filter) || strings.Contains(strings.ToLower(name), filter)
+}
+
+func (d *dock) onDock(ID string) bool {
+	for _, item := range d.items {
+		if item.ID == ID || desktopID(item.ID) == ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dock) focusFirstItem() {
+	for _, item := range append(append([]dockItem{}, d.items...), d.searchResults...) {
+		if item.box.IsVisible() {
+			item.button.GrabFocus()
+			return
+		}
+	}
+}
+
+// Activates the Nth item, as numbered on the dock, counting from 1
+func (d *dock) activateIndex(n int) {
+	if n > len(d.items) {
+		log.Debugf("No dock item #%v", n)
+		return
+	}
+	activateItem(d.items[n-1])
+	leaveKeyboardMode()
+}
+
+func (d *dock) activateFirstMatch() {
+	for _, item := range d.items {
+		if item.box.IsVisible() {
+			activateItem(item)
+			leaveKeyboardMode()
+			return
+		}
+	}
+	if len(d.searchResults) > 0 {
+		launch(d.searchResults[0].ID)
+		leaveKeyboardMode()
+	}
+}
+
+// Reads names of visible apps, the first .desktop file with the given ID wins, as in getExec
+func loadDesktopIndex() []desktopEntry {
+	var entries []desktopEntry
+	var seen []string
+	for _, d := range appDirs {
+		files, _ := os.ReadDir(d)
+		for _, f := range files {
+			ID, ok := strings.CutSuffix(f.Name(), ".desktop")
+			if !ok || isIn(seen, ID) {
+				continue
+			}
+			seen = append(seen, ID)
+			entry, visible := readDesktopEntry(filepath.Join(d, f.Name()))
+			if visible {
+				entry.ID = ID
+				entries = append(entries, entry)
+			}
+		}
+	}
+	log.Debugf("Desktop-file index: %v entries", len(entries))
+	return entries
+}
+
+func readDesktopEntry(path string) (desktopEntry, bool) {
+	var entry desktopEntry
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, false
+	}
+	defer file.Close()
+
+	inMainSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inMainSection = line == "[Desktop Entry]"
+			continue
+		}
+		if !inMainSection {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Name":
+			entry.Name = strings.TrimSpace(value)
+		case "NoDisplay", "Hidden":
+			if strings.TrimSpace(value) == "true" {
+				return entry, false
+			}
+		}
+	}
+	return entry, entry.Name != ""
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/gotk3/gotk3/glib"
+	log "github.com/sirupsen/logrus"
+)
+
+// socket2 events that may change which windows cover the dock; there's no event for resizing, though
+var layoutEvents = []string{"activewindowv2", "openwindow", "closewindow", "movewindowv2", "changefloatingmode",
+	"fullscreen", "workspacev2", "focusedmon", "moveworkspacev2", "activespecial"}
+
+var layoutCheck glib.SourceHandle
+
+// Called from the socket2 loop; several events usually come at a time, so we check once they settle
+func scheduleLayoutCheck() {
+	glib.IdleAdd(func() bool {
+		if layoutCheck > 0 {
+			glib.SourceRemove(layoutCheck)
+		}
+		layoutCheck = glib.TimeoutAdd(uint(100), func() bool {
+			layoutCheck = 0
+			checkLayout()
+			return false
+		})
+		return false
+	})
+}
+
+// Whether we follow the layout: in the modes that show and hide docks on their own
+func layoutWatched() bool {
+	return *fullscreenHide || *intellihide
+}
+
+// Hides or shows docks in the modes that do it on their own: fullscreen hide (-fh) and intellihide (-ih)
+func checkLayout() {
+	var mons []monitor
+	var wss []workspace
+	var wins []client
+	err := hyprctlJSON("j/monitors", &mons)
+	if err == nil {
+		err = hyprctlJSON("j/workspaces", &wss)
+	}
+	if err == nil {
+		err = hyprctlJSON("j/clients", &wins)
+	}
+	if err != nil {
+		log.Warnf("Couldn't list monitors, workspaces and clients: %s", err)
+		return
+	}
+
+	if *fullscreenHide {
+		updateFullscreen(mons, wss, wins)
+	}
+	if *intellihide {
+		updateIntellihide(mons, wins)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/coreos/go-systemd/v22/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+var logFormats = []string{"text", "json", "journald"}
+
+// Sends log entries to systemd-journald, w/ fields as journal fields, e.g. `journalctl --user CLASS=firefox`
+type journalHook struct {
+	identifier string
+}
+
+func (h journalHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h journalHook) Fire(entry *log.Entry) error {
+	vars := map[string]string{"SYSLOG_IDENTIFIER": h.identifier}
+	if *instanceName != "" {
+		vars["INSTANCE"] = *instanceName
+	}
+	for key, value := range entry.Data {
+		if field := journalField(key); field != "" {
+			vars[field] = fmt.Sprint(value)
+		}
+	}
+	return journal.Send(entry.Message, journalPriority(entry.Level), vars)
+}
+
+// Journal field names may only contain uppercase letters, digits and underscores, and must not start w/ an underscore
+func journalField(key string) string {
+	field := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+	return strings.TrimLeft(field, "_")
+}
+
+func journalPriority(level log.Level) journal.Priority {
+	switch level {
+	case log.PanicLevel:
+		return journal.PriEmerg
+	case log.FatalLevel:
+		return journal.PriCrit
+	case log.ErrorLevel:
+		return journal.PriErr
+	case log.WarnLevel:
+		return journal.PriWarning
+	case log.InfoLevel:
+		return journal.PriInfo
+	}
+	return journal.PriDebug
+}
+
+// Applies the -lf argument; falls back to text if the journal is not available
+func setupLogging() {
+	switch *logFormat {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	case "journald":
+		if !journal.Enabled() {
+			log.Warn("systemd-journald not available, logging as text")
+			return
+		}
+		log.AddHook(journalHook{identifier: "nwg-dock-hyprland"})
+		log.SetOutput(io.Discard)
+	}
+}
@@ -17,12 +17,10 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
-	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/allan-simon/go-singleinstance"
-	"github.com/dlasky/gotk3-layershell/layershell"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
@@ -42,72 +40,101 @@ var (
 	appDirs                            []string
 	clients                            []client
 	configDirectory                    string
+	cssFile                            string
+	cssProvider                        *gtk.CssProvider
 	dataHome                           string
 	detectorEnteredAt                  int64
+	docks                              []*dock
+	focusedOutput                      string
 	his                                string // $HYPRLAND_INSTANCE_SIGNATURE
 	hyprDir                            string // $XDG_RUNTIME_DIR/hypr since hyprland>0.39.1, earlier /tmp/hypr
 	ignoredWorkspaces                  []string
 	imgSizeScaled                      int
 	lastWinAddr                        string
-	mainBox                            *gtk.Box
 	monitors                           []monitor
 	oldClients                         []client
 	outerOrientation, innerOrientation gtk.Orientation
-	pinned                             []string
+	output2mon                         map[string]*gdk.Monitor
 	pinnedFile                         string
-	src                                glib.SourceHandle
+	refreshMainBox                     func(forceRefresh bool)
+	rules                              []appRule
 	widgetAnchor, menuAnchor           gdk.Gravity
-	win                                *gtk.Window
 	windowStateChannel                 chan WindowState = make(chan WindowState, 1)
 )
 
 // Flags
 var alignment = flag.String("a", "center", "Alignment in full width/height: \"start\", \"center\" or \"end\"")
+var animation = flag.String("an", "none", "ANimation of showing and hiding the dock: \"none\", \"slide\" or \"fade\"")
+var animationTime = flag.Int("at", 200, "Animation Time [ms]")
 var autohide = flag.Bool("d", false, "auto-hiDe: show dock when hotspot hovered, close when left or a button clicked")
+var closeDelay = flag.Int("cd", 1000, "Close Delay [ms]: hide the autohidden dock this long after the pointer left it")
 var cssFileName = flag.String("s", "style.css", "Styling: css file name")
 var debug = flag.Bool("debug", false, "turn on debug messages")
+var desktopButton = flag.Bool("desktop", false, "show the show-DESKTOP button: minimize all windows on the workspace, or restore them")
 var displayVersion = flag.Bool("v", false, "display Version information")
 var exclusive = flag.Bool("x", false, "set eXclusive zone: move other windows aside; overrides the \"-l\" argument")
 var full = flag.Bool("f", false, "take Full screen width/height")
+var fullscreenHide = flag.Bool("fh", false, "Fullscreen: Hide the dock while a fullscreen window is active on its monitor")
 var hotspotDelay = flag.Int64("hd", 20, "Hotspot Delay [ms]; the smaller, the faster mouse pointer needs to enter hotspot for the dock to appear; set 0 to disable")
+var hotspotLength = flag.Int("hl", 0, "Hotspot Length [px] along the screen edge; 0 for the dock's length, or the icon size in corners")
+var hotspotPlace = flag.String("hp", "dock", "Hotspot Place: \"dock\" (where the dock is), \"center\" of the screen edge, or \"corners\"")
+var hotspotPressure = flag.Int("hr", 1, "Hotspot pRessure: the number of pushes against the screen edge, within a second, to show the dock")
+var hotspotThickness = flag.Int("ht", 2, "Hotspot Thickness [px]")
 var ico = flag.String("ico", "", "alternative name or path for the launcher ICOn")
 var ignoreWorkspaces = flag.String("iw", "", "Ignore the running applications on these Workspaces based on the workspace's name or id, e.g. \"special,10\"")
 var imgSize = flag.Int("i", 48, "Icon size")
+var intellihide = flag.Bool("ih", false, "IntelliHide: stay resident, but hide while a window overlaps the dock; show on hotspot hovered")
 var launcherCmd = flag.String("c", "", "Command assigned to the launcher button")
 var launcherPos = flag.String("lp", "end", "Launcher button position, 'start' or 'end'")
 var layer = flag.String("l", "overlay", "Layer \"overlay\", \"top\" or \"bottom\"")
+var logFormat = flag.String("lf", "text", "Log Format: \"text\", \"json\" or \"journald\"")
 var marginBottom = flag.Int("mb", 0, "Margin Bottom")
 var marginLeft = flag.Int("ml", 0, "Margin Left")
 var marginRight = flag.Int("mr", 0, "Margin Right")
 var marginTop = flag.Int("mt", 0, "Margin Top")
+var mediaSeek = flag.Int("ms", 0, "Media players: Seek step [s] for scrolling on the button; set 0 to disable")
+var minimizeWorkspace = flag.String("mw", "minimized", "Minimize to the special Workspace of this name, w/o the \"special:\" prefix")
+var multiMonitor = flag.Bool("mm", false, "Multi-Monitor: one dock per monitor, each showing windows from its monitor only; overrides \"-o\"")
+var instanceName = flag.String("name", "", "Name of the dock instance, to run several docks side by side, e.g. \"tools\"")
 var noLauncher = flag.Bool("nolauncher", false, "don't show the launcher button")
 var numWS = flag.Int64("w", 10, "number of Workspaces you use")
-var position = flag.String("p", "bottom", "Position: \"bottom\", \"top\" or \"left\"")
+var pinnedPerMonitor = flag.Bool("pm", false, "Pinned items per Monitor in the multi-monitor mode, instead of shared by all docks")
+var position = flag.String("p", "bottom", "Position: \"bottom\", \"top\", \"left\" or \"right\"")
+var reservedAreas = flag.Bool("ra", false, "Reserved Areas: keep the overlay layer dock off the space reserved by other layer surfaces, e.g. bars")
 var resident = flag.Bool("r", false, "Leave the program resident, but w/o hotspot")
+var rulesFileName = flag.String("rules", "rules.json", "per-application Rules: json file name")
+var showDelay = flag.Int("sd", 0, "Show Delay [ms]: show the autohidden dock once the pointer rests on the hotspot this long")
+var showTray = flag.Bool("tray", false, "show the system Tray (StatusNotifierItem) section after the launcher button")
 var targetOutput = flag.String("o", "", "name of Output to display the dock on")
+var urgentBounce = flag.Bool("ub", false, "Urgent windows: Bounce the button (see the \"bounce\" style class)")
+var urgentReveal = flag.Int("ur", 0, "Urgent windows: Reveal the autohidden dock for this many [ms]; set 0 to disable")
 
-func buildMainBox(vbox *gtk.Box) {
-	if mainBox != nil {
-		mainBox.Destroy()
+func (d *dock) buildMainBox() {
+	if d.mainBox != nil {
+		d.mainBox.Destroy()
+		// destroyed along w/ the main box
+		d.searchResults = nil
+		d.progressBars = nil
 	}
-	mainBox, _ = gtk.BoxNew(innerOrientation, 0)
+	d.iconScale = d.scale()
+	d.mainBox, _ = gtk.BoxNew(innerOrientation, 0)
 
 	if *alignment == "start" {
-		vbox.PackStart(mainBox, false, true, 0)
+		d.alignmentBox.PackStart(d.mainBox, false, true, 0)
 	} else if *alignment == "end" {
-		vbox.PackEnd(mainBox, false, true, 0)
+		d.alignmentBox.PackEnd(d.mainBox, false, true, 0)
 	} else {
-		vbox.PackStart(mainBox, true, false, 0)
+		d.alignmentBox.PackStart(d.mainBox, true, false, 0)
 	}
 
 	var err error
-	pinned, err = loadTextFile(pinnedFile)
+	d.pinned, err = loadTextFile(d.pinnedFile)
 	if err != nil {
-		pinned = nil
+		d.pinned = nil
 	}
 
 	var allItems []string
-	for _, cntPin := range pinned {
+	for _, cntPin := range d.pinned {
 		if !isIn(allItems, cntPin) {
 			allItems = append(allItems, cntPin)
 		}
@@ -122,16 +149,22 @@ func buildMainBox(vbox *gtk.Box) {
 		}
 	})
 
-	// delete the clients that are on ignored workspaces
+	// delete the clients that are on ignored workspaces, or hidden by rules
 	clients = slices.DeleteFunc(clients, func(cl client) bool {
+		// minimized ones stay w/ "special" ignored, to be restored from the dock, unless ignored by the full name
+		if isMinimized(cl) {
+			return isIn(ignoredWorkspaces, cl.Workspace.Name) || isHidden(cl)
+		}
 		// only use the part in front of ":" if something like "special:scratch_term" is being used
 		clWorkspace, _, _ := strings.Cut(cl.Workspace.Name, ":")
-		return isIn(ignoredWorkspaces, strconv.Itoa(cl.Workspace.Id)) || isIn(ignoredWorkspaces, clWorkspace)
+		return isIn(ignoredWorkspaces, strconv.Itoa(cl.Workspace.Id)) || isIn(ignoredWorkspaces, clWorkspace) || isHidden(cl)
 	})
 
-	for _, cntTask := range clients {
-		if !isIn(allItems, cntTask.Class) && !strings.Contains(*launcherCmd, cntTask.Class) && cntTask.Class != "" {
-			allItems = append(allItems, cntTask.Class)
+	dockClients := d.monitorClients()
+	for _, cntTask := range dockClients {
+		ID := itemID(cntTask)
+		if !isIn(allItems, ID) && !strings.Contains(*launcherCmd, cntTask.Class) && cntTask.Class != "" {
+			allItems = append(allItems, ID)
 		}
 	}
 
@@ -149,38 +182,48 @@ func buildMainBox(vbox *gtk.Box) {
 	}
 
 	if *launcherPos == "start" {
-		button := launcherButton()
+		button := d.launcherButton()
 		if button != nil {
-			mainBox.PackStart(button, false, false, 0)
+			d.mainBox.PackStart(button, false, false, 0)
+		}
+		if button := d.desktopButton(); button != nil {
+			d.mainBox.PackStart(button, false, false, 0)
+		}
+		if tray := d.trayBox(); tray != nil {
+			d.mainBox.PackStart(tray, false, false, 0)
 		}
 	}
 
+	d.items = nil
 	var alreadyAdded []string
-	for _, pin := range pinned {
-		if !inTasks(pin) {
-			button := pinnedButton(pin)
-			mainBox.PackStart(button, false, false, 0)
+	for _, pin := range d.pinned {
+		if !inTasks(pin, dockClients) {
+			box, button := d.pinnedButton(pin)
+			d.mainBox.PackStart(box, false, false, 0)
+			d.items = append(d.items, dockItem{ID: pin, Pinned: true, box: box, button: button})
 		} else {
-			instances := taskInstances(pin)
+			instances := taskInstances(pin, dockClients)
 			c := instances[0]
 			if len(instances) == 1 {
-				button := taskButton(c, instances)
-				mainBox.PackStart(button, false, false, 0)
-				if c.Class == activeClient.Class && !*autohide {
-					button.SetProperty("name", "active")
+				box, button := d.taskButton(c, instances)
+				d.mainBox.PackStart(box, false, false, 0)
+				d.items = append(d.items, dockItem{ID: pin, Pinned: true, Instances: instances, box: box, button: button})
+				if isActive(c) && !*autohide {
+					box.SetProperty("name", "active")
 				} else {
-					button.SetProperty("name", "")
+					box.SetProperty("name", "")
 				}
-			} else if !isIn(alreadyAdded, c.Class) {
-				button := taskButton(c, instances)
-				mainBox.PackStart(button, false, false, 0)
-				if c.Class == activeClient.Class && !*autohide {
-					button.SetProperty("name", "active")
+			} else if !isIn(alreadyAdded, pin) {
+				box, button := d.taskButton(c, instances)
+				d.mainBox.PackStart(box, false, false, 0)
+				d.items = append(d.items, dockItem{ID: pin, Pinned: true, Instances: instances, box: box, button: button})
+				if isActive(c) && !*autohide {
+					box.SetProperty("name", "active")
 				} else {
-					button.SetProperty("name", "")
+					box.SetProperty("name", "")
 				}
-				alreadyAdded = append(alreadyAdded, c.Class)
-				clientMenu(c.Class, instances)
+				alreadyAdded = append(alreadyAdded, pin)
+				clientMenu(pin, instances)
 			} else {
 				continue
 			}
@@ -188,29 +231,32 @@ func buildMainBox(vbox *gtk.Box) {
 	}
 
 	alreadyAdded = nil
-	for _, t := range clients {
+	for _, t := range dockClients {
 		// For some time after killing a client, it's still being returned by 'j/clients', however w/o the Class value.
 		// Let's filter the ghosts out.
-		if !inPinned(t.Class) && t.Class != "" {
-			instances := taskInstances(t.Class)
+		ID := itemID(t)
+		if !d.isPinned(ID) && t.Class != "" {
+			instances := taskInstances(ID, dockClients)
 			if len(instances) == 1 {
-				button := taskButton(t, instances)
-				mainBox.PackStart(button, false, false, 0)
-				if t.Class == activeClient.Class && !*autohide {
-					button.SetProperty("name", "active")
+				box, button := d.taskButton(t, instances)
+				d.mainBox.PackStart(box, false, false, 0)
+				d.items = append(d.items, dockItem{ID: ID, Instances: instances, box: box, button: button})
+				if isActive(t) && !*autohide {
+					box.SetProperty("name", "active")
 				} else {
-					button.SetProperty("name", "")
+					box.SetProperty("name", "")
 				}
-			} else if !isIn(alreadyAdded, t.Class) {
-				button := taskButton(t, instances)
-				mainBox.PackStart(button, false, false, 0)
-				if t.Class == activeClient.Class && !*autohide {
-					button.SetProperty("name", "active")
+			} else if !isIn(alreadyAdded, ID) {
+				box, button := d.taskButton(t, instances)
+				d.mainBox.PackStart(box, false, false, 0)
+				d.items = append(d.items, dockItem{ID: ID, Instances: instances, box: box, button: button})
+				if isActive(t) && !*autohide {
+					box.SetProperty("name", "active")
 				} else {
-					button.SetProperty("name", "")
+					box.SetProperty("name", "")
 				}
-				alreadyAdded = append(alreadyAdded, t.Class)
-				clientMenu(t.Class, instances)
+				alreadyAdded = append(alreadyAdded, ID)
+				clientMenu(ID, instances)
 			} else {
 				continue
 			}
@@ -218,98 +264,23 @@ func buildMainBox(vbox *gtk.Box) {
 	}
 
 	if *launcherPos == "end" {
-		button := launcherButton()
+		button := d.launcherButton()
 		if button != nil {
-			mainBox.PackStart(button, false, false, 0)
+			d.mainBox.PackStart(button, false, false, 0)
 		}
-	}
-
-	mainBox.ShowAll()
-}
-
-func setupHotSpot(monitor gdk.Monitor, dockWindow *gtk.Window) gtk.Window {
-	w, h := dockWindow.GetSize()
-	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-
-	layershell.InitForWindow(win)
-	layershell.SetMonitor(win, &monitor)
-	layershell.SetNamespace(win, "nwg-dock-hotspot")
-
-	var box *gtk.Box
-	if *position == "bottom" || *position == "top" {
-		box, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
-	} else {
-		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
-	}
-	win.Add(box)
-
-	detectorBox, _ := gtk.EventBoxNew()
-	_ = detectorBox.SetProperty("name", "detector-box")
-
-	if *position == "bottom" {
-		box.PackStart(detectorBox, false, false, 0)
-	} else {
-		box.PackEnd(detectorBox, false, false, 0)
-	}
-
-	detectorBox.Connect("enter-notify-event", func() {
-		detectorEnteredAt = time.Now().UnixNano() / 1000000
-	})
-
-	hotspotBox, _ := gtk.EventBoxNew()
-	_ = hotspotBox.SetProperty("name", "hotspot-box")
-
-	if *position == "bottom" {
-		box.PackStart(hotspotBox, false, false, 0)
-	} else {
-		box.PackEnd(hotspotBox, false, false, 0)
-	}
-
-	hotspotBox.Connect("enter-notify-event", func() {
-		hotspotEnteredAt := time.Now().UnixNano() / 1000000
-		delay := hotspotEnteredAt - detectorEnteredAt
-		layershell.SetMonitor(dockWindow, &monitor)
-		if delay <= *hotspotDelay || *hotspotDelay == 0 {
-			log.Debugf("Delay %v < %v ms, let's show the window!", delay, *hotspotDelay)
-			dockWindow.Hide()
-			dockWindow.Show()
-		} else {
-			log.Debugf("Delay %v > %v ms, don't show the window :/", delay, *hotspotDelay)
+		if button := d.desktopButton(); button != nil {
+			d.mainBox.PackStart(button, false, false, 0)
 		}
-	})
-
-	if *position == "bottom" || *position == "top" {
-		detectorBox.SetSizeRequest(w, h/3)
-		hotspotBox.SetSizeRequest(w, 2)
-		if *position == "bottom" {
-			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, true)
-		} else {
-			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, true)
+		if tray := d.trayBox(); tray != nil {
+			d.mainBox.PackStart(tray, false, false, 0)
 		}
-
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, *full)
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_RIGHT, *full)
 	}
 
-	if *position == "left" {
-		detectorBox.SetSizeRequest(w/3, h)
-		hotspotBox.SetSizeRequest(2, h)
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, true)
-
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, *full)
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *full)
-	}
-
-	layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_OVERLAY)
-
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_TOP, *marginTop)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_LEFT, *marginLeft)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_RIGHT, *marginRight)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *marginBottom)
-
-	layershell.SetExclusiveZone(win, -1)
-
-	return *win
+	d.mainBox.ShowAll()
+	d.applyFilter()
+	d.resizeHotSpots()
+	busItemsChanged()
+	broadcastState()
 }
 
 func main() {
@@ -320,28 +291,78 @@ func main() {
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", flag.CommandLine.Name())
+		fmt.Fprintf(flag.CommandLine.Output(), " %s [check|import|ctl] [arguments]\n\n", flag.CommandLine.Name())
 		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nSubcommands:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), " check: validate arguments and config files, print the effective configuration as json\n")
+		fmt.Fprintf(flag.CommandLine.Output(), " import <nwg-dock|plank|latte|kde> [path]: add pinned items from another dock\n")
+		fmt.Fprintf(flag.CommandLine.Output(), " ctl <command>: control the running dock; commands: %s\n", ctlCommands)
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUsage of signals:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), " SIGRTMIN+1 (%s): toggle dock visibility (USR1 has been deprecated)\n", sigToggle)
 		fmt.Fprintf(flag.CommandLine.Output(), " SIGRTMIN+2 (%s): show the dock\n", sigShow)
 		fmt.Fprintf(flag.CommandLine.Output(), " SIGRTMIN+3 (%s): hide the dock\n", sigHide)
 	}
 
-	flag.Parse()
+	// Subcommand, if any, goes before the arguments
+	subcommand := ""
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		subcommand = os.Args[1]
+		_ = flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 	if *debug {
 		log.SetLevel(log.DebugLevel)
 	}
+	setupLogging()
+
+	switch subcommand {
+	case "":
+	case "check":
+		os.Exit(checkConfig())
+	case "import":
+		os.Exit(importPinned(flag.Args()))
+	case "ctl":
+		os.Exit(ctl(flag.Args()))
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "Unknown subcommand: '%s'\n", subcommand)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, problem := range validateFlags() {
+		log.Warn(problem)
+	}
 
 	if *autohide && *resident {
 		log.Warn("autohiDe and Resident arguments are mutually exclusive, ignoring -d!")
 		*autohide = false
 	}
+	if *intellihide && *autohide {
+		log.Warn("autohiDe and IntelliHide arguments are mutually exclusive, ignoring -ih!")
+		*intellihide = false
+	}
+	if *intellihide {
+		// it's the resident mode, w/ hiding on its own
+		*resident = true
+	}
+	if *multiMonitor && *targetOutput != "" {
+		log.Warn("-mm and -o arguments are mutually exclusive, ignoring -o!")
+		*targetOutput = ""
+	}
 
 	if *displayVersion {
 		fmt.Printf("nwg-dock-hyprland version %s\n", version)
 		os.Exit(0)
 	}
 
+	if !isValidInstanceName(*instanceName) {
+		log.Fatalf("Invalid instance name '%s': use letters, digits, '-' and '_' only", *instanceName)
+	}
+	if *instanceName != "" {
+		log.Infof("Instance name: '%s'", *instanceName)
+	}
+
 	his = os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
 	if his == "" {
 		log.Fatal("HYPRLAND_INSTANCE_SIGNATURE not found, terminating.")
@@ -359,7 +380,9 @@ func main() {
 	if *autohide {
 		log.Info("Starting in autohiDe mode")
 	}
-	if *resident {
+	if *intellihide {
+		log.Info("Starting in intellihide mode")
+	} else if *resident {
 		log.Info("Starting in resident mode")
 	}
 
@@ -377,7 +400,7 @@ func main() {
 			case syscall.SIGUSR1:
 				log.Warn("SIGUSR1 for toggling visibility is deprecated, use SIGRTMIN+1")
 				if *resident || *autohide {
-					if !win.IsVisible() {
+					if !docksVisible() {
 						log.Debug("SIGUSR1 received, showing the window")
 						windowStateChannel <- WindowShow
 					} else {
@@ -389,7 +412,7 @@ func main() {
 				}
 			case sigToggle:
 				if *resident || *autohide {
-					if !win.IsVisible() {
+					if !docksVisible() {
 						log.Debug("sigToggle received, showing the window")
 						windowStateChannel <- WindowShow
 					} else {
@@ -401,7 +424,7 @@ func main() {
 				}
 			case sigShow:
 				if *resident || *autohide {
-					if !win.IsVisible() {
+					if !docksVisible() {
 						log.Debug("sigShow received, showing the window")
 						windowStateChannel <- WindowShow
 					} else {
@@ -412,7 +435,7 @@ func main() {
 				}
 			case sigHide:
 				if *resident || *autohide {
-					if !win.IsVisible() {
+					if !docksVisible() {
 						log.Debug("sigHide received, but window already hidden, ignoring")
 					} else {
 						log.Debug("sigHide received, hiding the window")
@@ -431,8 +454,8 @@ func main() {
 	// Since v0.2 we can't just send SIGKILL if running instance found. We'll send SIGUSR1 instead.
 	// If it's running with `-r` or `-d` flag, it'll show the window. If not - it will die.
 
-	// Use md5-hashed $USER name to create unique lock files for multiple users
-	lockFilePath := fmt.Sprintf("%s/nwg-dock-%s.lock", tempDir(), md5Hash(os.Getenv("USER")))
+	// Use md5-hashed $USER name to create unique lock files for multiple users, and the instance name for multiple docks
+	lockFilePath := fmt.Sprintf("%s/%s.lock", tempDir(), userInstanceID())
 	lockFile, err := singleinstance.CreateLockFile(lockFilePath)
 	if err != nil {
 		pid, err := readTextFile(lockFilePath)
@@ -452,11 +475,7 @@ func main() {
 	defer lockFile.Close()
 
 	if !*noLauncher && *launcherCmd == "" {
-		if isCommand("nwg-drawer") {
-			*launcherCmd = "nwg-drawer"
-		} else if isCommand("nwggrid") {
-			*launcherCmd = "nwggrid -p"
-		}
+		*launcherCmd = detectLauncherCmd()
 
 		if *launcherCmd != "" {
 			log.Infof("Using auto-detected launcher command: '%s'", *launcherCmd)
@@ -484,131 +503,114 @@ func main() {
 	if cacheDirectory == "" {
 		log.Panic("Couldn't determine cache directory location")
 	}
-	pinnedFile = filepath.Join(cacheDirectory, "nwg-dock-pinned")
-	cssFile := filepath.Join(configDirectory, *cssFileName)
+	pinnedFile = filepath.Join(cacheDirectory, instanceID("nwg-dock-pinned"))
+
+	// Named instances use their own style-<name>.css, unless the css file name given explicitly
+	if *instanceName != "" && !isFlagPassed("s") {
+		*cssFileName = effectiveCssFileName()
+		if !pathExists(filepath.Join(configDirectory, *cssFileName)) {
+			err := copyFile(filepath.Join(configDirectory, "style.css"), filepath.Join(configDirectory, *cssFileName))
+			if err != nil {
+				log.Warnf("Error copying file: %s", err)
+			}
+		}
+	}
+	cssFile = filepath.Join(configDirectory, *cssFileName)
 	ignoredWorkspaces = strings.Split(*ignoreWorkspaces, ",")
+
+	rulesFile := filepath.Join(configDirectory, *rulesFileName)
+	rules, err = loadRules(rulesFile)
+	if err != nil {
+		log.Debugf("No rules loaded from %s: %s", rulesFile, err)
+	} else {
+		log.Infof("Using %v rule(s) from %s", len(rules), rulesFile)
+	}
 	log.Printf("Ignoring workspaces: %s\n", strings.Join(ignoredWorkspaces, ","))
 
 	appDirs = getAppDirs()
 
 	gtk.Init(nil)
 
-	cssProvider, _ := gtk.CssProviderNew()
+	cssProvider, _ = gtk.CssProviderNew()
 
 	err = cssProvider.LoadFromPath(cssFile)
 	if err != nil {
 		log.Warnf("%s file not found, using GTK styling\n", cssFile)
 	} else {
 		log.Printf("Using style: %s\n", cssFile)
-		screen, _ := gdk.ScreenGetDefault()
-		gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
-	}
-
-	win, err = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-	if err != nil {
-		log.Fatal("Unable to create window:", err)
-	}
-
-	layershell.InitForWindow(win)
-	layershell.SetNamespace(win, "nwg-dock")
-
-	var output2mon map[string]*gdk.Monitor
-	if *targetOutput != "" {
-		// We want to assign layershell to a monitor, but we only know the output name!
-		output2mon, err = mapOutputs()
-		if err == nil {
-			layershell.SetMonitor(win, output2mon[*targetOutput])
-		} else {
-			log.Warn(fmt.Sprintf("Couldn't assign layershell to monitor: %s", err))
-		}
 	}
+	// we attach the provider anyway, for the style to be applied on reload, if the file appears later
+	screen, _ := gdk.ScreenGetDefault()
+	gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
 
 	if *exclusive {
-		layershell.AutoExclusiveZoneEnable(win)
 		*layer = "top"
 	}
 
+	if *position == "bottom" {
+		widgetAnchor = gdk.GDK_GRAVITY_NORTH
+		menuAnchor = gdk.GDK_GRAVITY_SOUTH
+	} else if *position == "top" {
+		widgetAnchor = gdk.GDK_GRAVITY_SOUTH
+		menuAnchor = gdk.GDK_GRAVITY_NORTH
+	} else if *position == "left" {
+		widgetAnchor = gdk.GDK_GRAVITY_EAST
+		menuAnchor = gdk.GDK_GRAVITY_WEST
+	} else {
+		widgetAnchor = gdk.GDK_GRAVITY_WEST
+		menuAnchor = gdk.GDK_GRAVITY_EAST
+	}
 	if *position == "bottom" || *position == "top" {
-		if *position == "bottom" {
-			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, true)
-
-			widgetAnchor = gdk.GDK_GRAVITY_NORTH
-			menuAnchor = gdk.GDK_GRAVITY_SOUTH
-		} else {
-			layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, true)
-
-			widgetAnchor = gdk.GDK_GRAVITY_SOUTH
-			menuAnchor = gdk.GDK_GRAVITY_NORTH
-		}
-
 		outerOrientation = gtk.ORIENTATION_VERTICAL
 		innerOrientation = gtk.ORIENTATION_HORIZONTAL
-
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, *full)
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_RIGHT, *full)
-	}
-
-	if *position == "left" {
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_LEFT, true)
-
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_TOP, *full)
-		layershell.SetAnchor(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *full)
-
+	} else {
 		outerOrientation = gtk.ORIENTATION_HORIZONTAL
 		innerOrientation = gtk.ORIENTATION_VERTICAL
-
-		widgetAnchor = gdk.GDK_GRAVITY_EAST
-		menuAnchor = gdk.GDK_GRAVITY_WEST
 	}
 
-	if *layer == "top" {
-		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_TOP)
-	} else if *layer == "bottom" {
-		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_BOTTOM)
+	if *multiMonitor {
+		output2mon, err = mapOutputs()
+		if err != nil {
+			log.Fatalf("Couldn't map outputs to monitors: %s", err)
+		}
+		for _, m := range monitors {
+			if m.Focused {
+				focusedOutput = m.Name
+			}
+			mon, err := outputMonitor(output2mon, m.Name)
+			if err != nil {
+				log.Warnf("Skipping output %s: %s", m.Name, err)
+				continue
+			}
+			docks = append(docks, newDock(m.Name, mon))
+		}
+		if len(docks) == 0 {
+			log.Fatal("No outputs to show the dock on")
+		}
+		log.Infof("Multi-monitor mode, %v dock(s)", len(docks))
 	} else {
-		layershell.SetLayer(win, layershell.LAYER_SHELL_LAYER_OVERLAY)
-		layershell.SetExclusiveZone(win, -1)
-	}
-
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_TOP, *marginTop)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_LEFT, *marginLeft)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_RIGHT, *marginRight)
-	layershell.SetMargin(win, layershell.LAYER_SHELL_EDGE_BOTTOM, *marginBottom)
-
-	win.Connect("destroy", func() {
-		gtk.MainQuit()
-	})
-
-	// Close the window on leave, but not immediately, to avoid accidental closes
-	win.Connect("leave-notify-event", func() {
-		if *autohide {
-			src = glib.TimeoutAdd(uint(1000), func() bool {
-				win.Hide()
-				src = 0
-				return false
-			})
+		var mon *gdk.Monitor
+		if *targetOutput != "" || *intellihide {
+			// We want to assign layershell to a monitor, but we only know the output name!
+			// Intellihide needs to know which monitor the dock is on, too.
+			output2mon, err = mapOutputs()
+			if err == nil && *targetOutput != "" {
+				mon, err = outputMonitor(output2mon, *targetOutput)
+			}
+			if err != nil {
+				log.Warn(fmt.Sprintf("Couldn't assign layershell to monitor: %s", err))
+			}
 		}
-	})
-
-	win.Connect("enter-notify-event", func() {
-		cancelClose()
-	})
-
-	outerBox, _ := gtk.BoxNew(outerOrientation, 0)
-	_ = outerBox.SetProperty("name", "box")
-	win.Add(outerBox)
-
-	alignmentBox, _ := gtk.BoxNew(innerOrientation, 0)
-	outerBox.PackStart(alignmentBox, true, true, 0)
-
-	mainBox, _ = gtk.BoxNew(innerOrientation, 0)
-	// We'll pack mainBox later, in buildMainBox
+		docks = append(docks, newDock("", mon))
+	}
 
 	oldClients = clients
-	refreshMainBox := func(forceRefresh bool) {
+	refreshMainBox = func(forceRefresh bool) {
 		if forceRefresh || (len(clients) != len(oldClients)) {
 			glib.TimeoutAdd(0, func() bool {
-				buildMainBox(alignmentBox)
+				for _, d := range docks {
+					d.buildMainBox()
+				}
 				oldClients = clients
 				return false
 			})
@@ -619,53 +621,65 @@ func main() {
 	if err != nil {
 		log.Fatalf("Couldn't list clients: %s", err)
 	}
-	buildMainBox(alignmentBox)
-
-	win.ShowAll()
+	for _, d := range docks {
+		d.buildMainBox()
+	}
 
-	if *autohide {
-		glib.TimeoutAdd(uint(500), win.Hide)
+	listener, err := startControlServer()
+	if err != nil {
+		log.Warnf("Couldn't create control socket: %s", err)
+	} else {
+		defer listener.Close()
+	}
 
-		mRefProvider, _ := gtk.CssProviderNew()
-		css := "window { background-color: rgba (0, 0, 0, 0); border: none}"
-		err := mRefProvider.LoadFromData(css)
-		if err != nil {
-			log.Warn(err)
-		}
+	err = startBusService()
+	if err != nil {
+		log.Warnf("Couldn't start D-Bus service: %s", err)
+	} else {
+		defer stopBusService()
+		busItemsChanged()
+	}
 
-		if *targetOutput == "" {
-			// hot spots on all displays
-			monitors, _ := listGdkMonitors()
-			for _, monitor := range monitors {
-				win := setupHotSpot(monitor, win)
+	err = startLauncherEntryListener()
+	if err != nil {
+		log.Warnf("Couldn't subscribe to LauncherEntry signals: %s", err)
+	}
 
-				ctx, _ := win.GetStyleContext()
-				ctx.AddProvider(mRefProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	err = startMediaPlayerWatcher()
+	if err != nil {
+		log.Warnf("Couldn't follow MPRIS media players: %s", err)
+	}
 
-				win.ShowAll()
-			}
-		} else {
-			// hot spot on the selected display only
-			monitor := output2mon[*targetOutput]
-			win := setupHotSpot(*monitor, win)
+	if *showTray {
+		err = startTray()
+		if err != nil {
+			log.Warnf("Couldn't start the system tray: %s", err)
+		}
+	}
 
-			ctx, _ := win.GetStyleContext()
-			ctx.AddProvider(mRefProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+	for _, d := range docks {
+		d.win.ShowAll()
+	}
 
-			win.ShowAll()
-		}
+	if *autohide {
+		glib.TimeoutAdd(uint(500), hideDocks)
 	}
+	if layoutWatched() {
+		scheduleLayoutCheck()
+	}
+	setupHotSpots()
+	watchMonitors()
 
 	go func() {
 		for {
 			windowState := <-windowStateChannel
 
 			glib.TimeoutAdd(0, func() bool {
-				if windowState == WindowShow && win != nil && !win.IsVisible() {
-					win.ShowAll()
+				if windowState == WindowShow {
+					showDocks()
 				}
-				if windowState == WindowHide && win != nil && win.IsVisible() {
-					win.Hide()
+				if windowState == WindowHide {
+					hideDocks()
 				}
 
 				return false
@@ -681,8 +695,7 @@ func main() {
 	go func() {
 		conn, err := net.DialUnix("unix", nil, addr)
 		if err != nil {
-			fmt.Println("Error connecting to the socket:", err)
-			os.Exit(1)
+			log.WithField("socket", addr.Name).Fatalf("Error connecting to socket2: %s", err)
 		}
 		defer conn.Close()
 
@@ -690,20 +703,59 @@ func main() {
 			buf := make([]byte, 10240)
 			n, err := conn.Read(buf)
 			if err != nil {
-				fmt.Println("Error reading from socket2:", err)
+				log.WithField("socket", addr.Name).Fatalf("Error reading from socket2: %s", err)
 			}
 
-			s := string(buf[:n])
-			if strings.Contains(s, "activewindowv2") {
-				winAddr := strings.TrimSpace(strings.Split(s, "activewindowv2>>")[1])
-				if winAddr != lastWinAddr && !strings.Contains(winAddr, ">>") {
+			// we may receive several `EVENT>>DATA` lines at a time
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				event, data, found := strings.Cut(line, ">>")
+				if !found {
+					continue
+				}
+				if layoutWatched() && isIn(layoutEvents, event) {
+					scheduleLayoutCheck()
+				}
+				switch event {
+				case "activewindowv2":
+					winAddr := strings.TrimSpace(data)
+					if winAddr != lastWinAddr {
+						clearUrgent(winAddr)
+						err = listClients()
+						if err != nil {
+							log.Fatalf("Couldn't list clients: %s", err)
+						} else {
+							refreshMainBox(true)
+						}
+						lastWinAddr = winAddr
+					}
+				case "focusedmon":
+					// MONNAME,WORKSPACENAME
+					output, _, _ := strings.Cut(data, ",")
+					glib.IdleAdd(func() bool {
+						focusedOutput = output
+						broadcastState()
+						return false
+					})
+				case "monitoradded", "monitorremoved":
+					glib.IdleAdd(func() bool {
+						scheduleMonitorsUpdate()
+						return false
+					})
+				case "movewindowv2":
+					// minimizing and restoring windows moves them w/o focus changes
 					err = listClients()
 					if err != nil {
 						log.Fatalf("Couldn't list clients: %s", err)
 					} else {
 						refreshMainBox(true)
 					}
-					lastWinAddr = winAddr
+				case "urgent":
+					markUrgent(strings.TrimSpace(data))
+				case "closewindow":
+					forgetMinimized(strings.TrimSpace(data))
+					if clearUrgent(strings.TrimSpace(data)) {
+						refreshMainBox(true)
+					}
 				}
 			}
 		}
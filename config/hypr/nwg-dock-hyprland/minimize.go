@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Hyprland has no minimize, so we move windows to a special workspace (-mw), and remember where they came from.
+Addresses w/ the "0x" prefix, as in j/clients.
+*/
+var (
+	minimizedFrom  = make(map[string]string)
+	desktopWindows []string // minimized by show-desktop, to be restored by the next click
+	minimizedMutex sync.Mutex
+)
+
+func minimizedWorkspace() string {
+	return fmt.Sprintf("special:%s", *minimizeWorkspace)
+}
+
+func isMinimized(c client) bool {
+	return c.Workspace.Name == minimizedWorkspace()
+}
+
+func workspaceLabel(c client) string {
+	if isMinimized(c) {
+		return "minimized"
+	}
+	return c.Workspace.Name
+}
+
+func allMinimized(instances []client) bool {
+	for _, c := range instances {
+		if !isMinimized(c) {
+			return false
+		}
+	}
+	return len(instances) > 0
+}
+
+// Workspace as the movetoworkspace dispatcher takes it: the id of a numbered workspace, the name otherwise
+func workspaceTarget(id int, name string) string {
+	if name == strconv.Itoa(id) || strings.HasPrefix(name, "special") {
+		return name
+	}
+	return fmt.Sprintf("name:%s", name)
+}
+
+func minimizeClient(c client) {
+	if isMinimized(c) {
+		return
+	}
+	minimizedMutex.Lock()
+	minimizedFrom[c.Address] = workspaceTarget(c.Workspace.Id, c.Workspace.Name)
+	minimizedMutex.Unlock()
+	_, _ = hyprDispatch(fmt.Sprintf("movetoworkspacesilent %s,address:%s", minimizedWorkspace(), c.Address), &c)
+}
+
+// Moves the window back to where it was minimized from, or to the current workspace, if we don't know
+func restoreClient(c client, focus bool) {
+	minimizedMutex.Lock()
+	target, ok := minimizedFrom[c.Address]
+	delete(minimizedFrom, c.Address)
+	minimizedMutex.Unlock()
+	if !ok {
+		target = "+0"
+	}
+
+	_, _ = hyprDispatch(fmt.Sprintf("movetoworkspacesilent %s,address:%s", target, c.Address), &c)
+	if focus {
+		_, _ = hyprDispatch(fmt.Sprintf("focuswindow address:%s", c.Address), &c)
+	}
+}
+
+// Handles the socket2 `closewindow>>ADDRESS` event, the address comes w/o the "0x" prefix
+func forgetMinimized(winAddr string) {
+	minimizedMutex.Lock()
+	delete(minimizedFrom, "0x"+winAddr)
+	minimizedMutex.Unlock()
+}
+
+// Minimizes all windows on the active workspace, or restores the ones minimized this way; returns the action taken
+func toggleDesktop() (string, error) {
+	var wins []client
+	err := hyprctlJSON("j/clients", &wins)
+	if err != nil {
+		return "", err
+	}
+
+	minimizedMutex.Lock()
+	restore := desktopWindows
+	desktopWindows = nil
+	minimizedMutex.Unlock()
+
+	if len(restore) > 0 {
+		for _, c := range wins {
+			if isIn(restore, c.Address) && isMinimized(c) {
+				restoreClient(c, false)
+			}
+		}
+		return "restored", nil
+	}
+
+	var active workspace
+	err = hyprctlJSON("j/activeworkspace", &active)
+	if err != nil {
+		return "", err
+	}
+	var minimized []string
+	for _, c := range wins {
+		if c.Workspace.Id == active.Id && c.Mapped {
+			minimizeClient(c)
+			minimized = append(minimized, c.Address)
+		}
+	}
+	minimizedMutex.Lock()
+	desktopWindows = minimized
+	minimizedMutex.Unlock()
+	return "minimized", nil
+}
+
+func (d *dock) desktopButton() *gtk.Button {
+	if !*desktopButton {
+		return nil
+	}
+	button, _ := gtk.ButtonNew()
+	image, err := createImage("user-desktop", imgSizeScaled, d.iconScale)
+	if err != nil || image == nil {
+		image, _ = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/icon-missing.svg"),
+			imgSizeScaled, imgSizeScaled, d.iconScale)
+	}
+	if image != nil {
+		button.SetImage(image)
+		button.SetAlwaysShowImage(true)
+	}
+	button.SetTooltipText("Show desktop")
+	ctx, _ := button.GetStyleContext()
+	ctx.AddClass("desktop")
+
+	button.Connect("clicked", func() {
+		action, err := toggleDesktop()
+		if err != nil {
+			log.Warnf("Couldn't toggle the desktop: %s", err)
+			return
+		}
+		log.Debugf("Show desktop: %s", action)
+		if *autohide {
+			hideDocks()
+		}
+	})
+	button.Connect("enter-notify-event", d.cancelClose)
+	return button
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestWorkspaceTarget(t *testing.T) {
+	tests := []struct {
+		id   int
+		name string
+		want string
+	}{
+		{1, "1", "1"},
+		{10, "10", "10"},
+		{3, "web", "name:web"},
+		{4, "2", "name:2"},
+		{-98, "special:scratchpad", "special:scratchpad"},
+		{-99, "special", "special"},
+	}
+	for _, tt := range tests {
+		if got := workspaceTarget(tt.id, tt.name); got != tt.want {
+			t.Errorf("workspaceTarget(%v, %q) = %q, want %q", tt.id, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	log "github.com/sirupsen/logrus"
+)
+
+var monitorsUpdate glib.SourceHandle
+
+// Follows GDK monitors being added and removed; Hyprland events are handled in the socket2 loop
+func watchMonitors() {
+	display, err := gdk.DisplayGetDefault()
+	if err != nil {
+		log.Warnf("Couldn't watch monitors: %s", err)
+		return
+	}
+	display.Connect("monitor-added", scheduleMonitorsUpdate)
+	display.Connect("monitor-removed", scheduleMonitorsUpdate)
+}
+
+/*
+Plugging in a monitor fires both GDK signals and Hyprland events, and GDK may learn of it before
+or after Hyprland does, so we wait for things to settle, and then update once.
+*/
+func scheduleMonitorsUpdate() {
+	if monitorsUpdate > 0 {
+		glib.SourceRemove(monitorsUpdate)
+	}
+	monitorsUpdate = glib.TimeoutAdd(uint(500), func() bool {
+		monitorsUpdate = 0
+		updateMonitors()
+		return false
+	})
+}
+
+// Adds docks on new outputs and removes the ones on vanished outputs, or moves the single dock
+func updateMonitors() {
+	var err error
+	output2mon, err = mapOutputs()
+	if err != nil {
+		log.Warnf("Couldn't map outputs to monitors: %s", err)
+		return
+	}
+	log.Debugf("Monitors changed, %v output(s) mapped", len(output2mon))
+
+	if *multiMonitor {
+		visible := docksVisible()
+		var kept, gone []*dock
+		for _, d := range docks {
+			if mon, ok := output2mon[d.output]; ok {
+				d.setMonitor(mon)
+				kept = append(kept, d)
+			} else {
+				gone = append(gone, d)
+			}
+		}
+		// w/ no outputs mapped (e.g. all unplugged at once), keep the last dock for the compositor to place
+		if len(kept) == 0 && len(gone) > 0 && len(output2mon) == 0 {
+			d := gone[len(gone)-1]
+			gone = gone[:len(gone)-1]
+			log.WithField("output", d.output).Info("No outputs mapped, moving the dock")
+			d.setMonitor(nil)
+			kept = append(kept, d)
+		}
+		for _, d := range gone {
+			log.WithField("output", d.output).Info("Output gone, removing its dock")
+			d.destroy()
+		}
+		docks = kept
+
+		for _, m := range monitors {
+			mon, ok := output2mon[m.Name]
+			if !ok || dockOn(m.Name) != nil {
+				continue
+			}
+			log.WithField("output", m.Name).Info("Output added, creating a dock")
+			d := newDock(m.Name, mon)
+			d.buildMainBox()
+			docks = append(docks, d)
+			if visible || !(*autohide || *resident) {
+				d.win.ShowAll()
+			}
+		}
+	} else {
+		d := docks[0]
+		if *targetOutput != "" {
+			if mon, ok := output2mon[*targetOutput]; ok {
+				if !sameMonitor(d.monitor, mon) {
+					log.WithField("output", *targetOutput).Info("Output back, moving the dock")
+				}
+				d.setMonitor(mon)
+			} else if d.monitor != nil {
+				log.WithField("output", *targetOutput).Info("Output gone, moving the dock")
+				d.setMonitor(nil)
+			}
+		} else if d.monitor != nil && !monitorMapped(d.monitor) {
+			log.Info("Dock's monitor gone, moving the dock")
+			d.setMonitor(nil)
+		}
+	}
+
+	setupHotSpots()
+	refreshMainBox(true)
+	if layoutWatched() {
+		checkLayout()
+	}
+}
+
+func dockOn(output string) *dock {
+	for _, d := range docks {
+		if d.output == output {
+			return d
+		}
+	}
+	return nil
+}
+
+func monitorMapped(mon *gdk.Monitor) bool {
+	for _, m := range output2mon {
+		if sameMonitor(m, mon) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameMonitor(a, b *gdk.Monitor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Native() == b.Native()
+}
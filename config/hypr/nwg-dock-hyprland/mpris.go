@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mprisPrefix      = "org.mpris.MediaPlayer2."
+	mprisPath        = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+	mprisTimeout     = time.Second // to wait for a player to answer
+)
+
+// MPRIS2 player on the session bus; we match it to dock items by the desktop entry, or by the client PID
+type mediaPlayer struct {
+	busName      string
+	owner        string // unique name, the sender of PropertiesChanged signals
+	desktopEntry string
+	pid          uint32
+	status       string // "Playing", "Paused" or "Stopped"
+	track        string // "Artist - Title" of the current track, if any
+}
+
+// Players are kept up to date from bus signals, so that rebuilding the dock doesn't wait for any of them
+var (
+	mprisConn         *dbus.Conn
+	mediaPlayers      []*mediaPlayer
+	mediaPlayersMutex sync.Mutex
+)
+
+// Connects to the session bus, and starts following players appearing, changing and leaving
+func startMediaPlayerWatcher() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+
+	for _, match := range [][]dbus.MatchOption{
+		{dbus.WithMatchInterface("org.freedesktop.DBus"), dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg0Namespace(strings.TrimSuffix(mprisPrefix, "."))},
+		{dbus.WithMatchObjectPath(mprisPath), dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"), dbus.WithMatchArg(0, mprisPlayerIface)},
+	} {
+		err = conn.AddMatchSignal(match...)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	signals := make(chan *dbus.Signal, 20)
+	conn.Signal(signals)
+	mprisConn = conn
+
+	go func() {
+		var names []string
+		err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
+		if err != nil {
+			log.Debugf("MPRIS: couldn't list bus names: %s", err)
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, mprisPrefix) {
+				addMediaPlayer(name, "")
+			}
+		}
+
+		for s := range signals {
+			handleMediaPlayerSignal(s)
+		}
+	}()
+	return nil
+}
+
+func handleMediaPlayerSignal(s *dbus.Signal) {
+	switch s.Name {
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		if len(s.Body) != 3 {
+			return
+		}
+		name, _ := s.Body[0].(string)
+		newOwner, _ := s.Body[2].(string)
+		if !strings.HasPrefix(name, mprisPrefix) {
+			return
+		}
+		removeMediaPlayer(name)
+		if newOwner != "" {
+			addMediaPlayer(name, newOwner)
+		}
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if len(s.Body) < 2 {
+			return
+		}
+		changed, _ := s.Body[1].(map[string]dbus.Variant)
+		mediaPlayersMutex.Lock()
+		var player *mediaPlayer
+		for _, p := range mediaPlayers {
+			if p.owner == s.Sender {
+				player = p
+				break
+			}
+		}
+		update := player != nil && player.update(changed)
+		mediaPlayersMutex.Unlock()
+		if update {
+			refreshMainBox(true)
+		}
+	}
+}
+
+// Reads properties of a player that appeared on the bus
+func addMediaPlayer(name, owner string) {
+	player := &mediaPlayer{busName: name, owner: owner}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mprisTimeout)
+	defer cancel()
+	if owner == "" {
+		err := mprisConn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&player.owner)
+		if err != nil {
+			log.Debugf("MPRIS: player %s has no owner: %s", name, err)
+			return
+		}
+	}
+	_ = mprisConn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetConnectionUnixProcessID", 0, name).
+		Store(&player.pid)
+
+	obj := mprisConn.Object(name, mprisPath)
+	var v dbus.Variant
+	if obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, "org.mpris.MediaPlayer2", "DesktopEntry").Store(&v) == nil {
+		player.desktopEntry, _ = v.Value().(string)
+	}
+	var props map[string]dbus.Variant
+	if err := obj.CallWithContext(ctx, dbusPropsGetAll, 0, mprisPlayerIface).Store(&props); err != nil {
+		log.Debugf("MPRIS: couldn't get %s properties: %s", name, err)
+	}
+	player.update(props)
+
+	mediaPlayersMutex.Lock()
+	mediaPlayers = append(mediaPlayers, player)
+	mediaPlayersMutex.Unlock()
+
+	log.Debugf("MPRIS: player added: %s (%s)", name, player.desktopEntry)
+	refreshMainBox(true)
+}
+
+func removeMediaPlayer(name string) {
+	mediaPlayersMutex.Lock()
+	var kept []*mediaPlayer
+	for _, p := range mediaPlayers {
+		if p.busName != name {
+			kept = append(kept, p)
+		}
+	}
+	changed := len(kept) != len(mediaPlayers)
+	mediaPlayers = kept
+	mediaPlayersMutex.Unlock()
+
+	if changed {
+		refreshMainBox(true)
+	}
+}
+
+// Applies changed Player properties; returns true if anything we show changed
+func (p *mediaPlayer) update(props map[string]dbus.Variant) bool {
+	status, track := p.status, p.track
+	if v, ok := props["PlaybackStatus"]; ok {
+		p.status, _ = v.Value().(string)
+	}
+	if v, ok := props["Metadata"]; ok {
+		metadata, _ := v.Value().(map[string]dbus.Variant)
+		title, _ := metadata["xesam:title"].Value().(string)
+		artists, _ := metadata["xesam:artist"].Value().([]string)
+		p.track = title
+		if title != "" && len(artists) > 0 {
+			p.track = fmt.Sprintf("%s - %s", strings.Join(artists, ", "), title)
+		}
+	}
+	return p.status != status || p.track != track
+}
+
+// Returns a copy of the player matching the dock item, if any
+func mediaPlayerFor(ID string, instances []client) *mediaPlayer {
+	mediaPlayersMutex.Lock()
+	defer mediaPlayersMutex.Unlock()
+
+	for _, player := range mediaPlayers {
+		found := player.desktopEntry != "" &&
+			(strings.EqualFold(player.desktopEntry, ID) || strings.EqualFold(player.desktopEntry, desktopID(ID)))
+		// e.g. org.mpris.MediaPlayer2.spotify w/o the desktop entry
+		found = found || strings.EqualFold(strings.TrimPrefix(player.busName, mprisPrefix), ID)
+		for _, c := range instances {
+			found = found || (player.pid != 0 && int(player.pid) == c.Pid)
+		}
+		if found {
+			p := *player
+			return &p
+		}
+	}
+	return nil
+}
+
+// Doesn't wait for the reply, not to block the GTK thread
+func (p *mediaPlayer) call(method string, args ...interface{}) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mprisTimeout)
+		defer cancel()
+		call := mprisConn.Object(p.busName, mprisPath).CallWithContext(ctx,
+			fmt.Sprintf("%s.%s", mprisPlayerIface, method), 0, args...)
+		log.Debugf("MPRIS %s %s -> %v", p.busName, method, call.Err)
+	}()
+}
+
+// Appends the track, if any, to the button tooltip
+func mediaTooltip(tooltip string, player *mediaPlayer) string {
+	if player == nil {
+		return tooltip
+	}
+	track := player.track
+	if track == "" {
+		return tooltip
+	}
+	if player.status == "Playing" {
+		return fmt.Sprintf("%s\n▶ %s", tooltip, track)
+	}
+	return fmt.Sprintf("%s\n⏸ %s", tooltip, track)
+}
+
+// Prepends media controls to the client context menu
+func mediaMenuItems(menu *gtk.Menu, player *mediaPlayer) {
+	if player == nil {
+		return
+	}
+
+	if track := player.track; track != "" {
+		if runes := []rune(track); len(runes) > 40 {
+			track = string(runes[:40])
+		}
+		trackItem, _ := gtk.MenuItemNewWithLabel(track)
+		trackItem.SetSensitive(false)
+		menu.Append(trackItem)
+	}
+
+	label := "Play"
+	if player.status == "Playing" {
+		label = "Pause"
+	}
+	playPause, _ := gtk.MenuItemNewWithLabel(label)
+	playPause.Connect("activate", func() {
+		player.call("PlayPause")
+	})
+	menu.Append(playPause)
+
+	next, _ := gtk.MenuItemNewWithLabel("Next")
+	next.Connect("activate", func() {
+		player.call("Next")
+	})
+	menu.Append(next)
+
+	previous, _ := gtk.MenuItemNewWithLabel("Previous")
+	previous.Connect("activate", func() {
+		player.call("Previous")
+	})
+	menu.Append(previous)
+
+	separator, _ := gtk.SeparatorMenuItemNew()
+	menu.Append(separator)
+}
+
+// Scrolling on the button seeks the track by the -ms seconds
+func connectMediaSeek(button *gtk.Button, player *mediaPlayer) {
+	if player == nil || *mediaSeek <= 0 {
+		return
+	}
+	button.AddEvents(int(gdk.SCROLL_MASK))
+	button.Connect("scroll-event", func(btn *gtk.Button, e *gdk.Event) bool {
+		scrollEvent := gdk.EventScrollNewFromEvent(e)
+		offset := int64(*mediaSeek) * 1000000 // µs
+		switch scrollEvent.Direction() {
+		case gdk.SCROLL_UP:
+			player.call("Seek", offset)
+		case gdk.SCROLL_DOWN:
+			player.call("Seek", -offset)
+		default:
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/dlasky/gotk3-layershell/layershell"
+)
+
+// Order of the Hyprland monitor `reserved` values
+var reservedEdges = []layershell.LayerShellEdgeFlags{layershell.LAYER_SHELL_EDGE_LEFT, layershell.LAYER_SHELL_EDGE_TOP,
+	layershell.LAYER_SHELL_EDGE_RIGHT, layershell.LAYER_SHELL_EDGE_BOTTOM}
+
+/*
+Whether the compositor places the dock in the area left by other layer surfaces (bars, panels) and `addreserved`
+monitor rules: the overlay layer ignores them, unless -ra, as we set the exclusive zone to -1 there. W/ -x the dock
+itself gets stacked after other exclusive zones.
+*/
+func avoidsReserved() bool {
+	return *exclusive || *layer != "overlay" || *reservedAreas
+}
+
+/*
+Space reserved by others at the edge of the monitor, that the dock gets moved off. Hyprland computes `reserved`
+in the layout coordinates, so it's already in the orientation of a transformed (rotated, flipped) monitor.
+W/ -x it includes our own exclusive zone, which we can't tell apart, so we leave it out.
+*/
+func reservedAt(m monitor, edge layershell.LayerShellEdgeFlags) int {
+	if *exclusive || !avoidsReserved() || len(m.Reserved) < len(reservedEdges) {
+		return 0
+	}
+	for i, e := range reservedEdges {
+		if e == edge {
+			return m.Reserved[i]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Per-application rule, matched against the client class with a regular expression, e.g.:
+
+	[
+	  {"class": "^polkit-gnome-authentication-agent-1$", "hide": true},
+	  {"class": "^Code$", "alias": "code-oss"},
+	  {"class": "^firefox$", "icon": "/usr/share/icons/my-firefox.svg", "name": "Web"},
+	  {"class": "^firefox-work$", "group": "firefox-work", "alias": "firefox"}
+	]
+
+alias: desktop ID to use for the icon, name and launch command lookup;
+icon: icon name or path to use instead of the one from the .desktop file;
+name: tooltip text to use instead of the Name= from the .desktop file;
+hide: never show the app running instances on the dock;
+group: put matching clients in their own group, instead of sharing it w/ other clients of a similar class
+(set the alias as well, for the icon and the launch command to be found).
+*/
+type appRule struct {
+	Class string `json:"class"`
+	Alias string `json:"alias"`
+	Icon  string `json:"icon"`
+	Name  string `json:"name"`
+	Hide  bool   `json:"hide"`
+	Group string `json:"group"`
+
+	FullscreenDock bool `json:"fullscreenDock"`
+
+	re *regexp.Regexp
+}
+
+func loadRules(path string) ([]appRule, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, problems, err := parseRules(bytes)
+	for _, problem := range problems {
+		log.Warnf("Skipping rule: %s", problem)
+	}
+	return result, err
+}
+
+// Returns valid rules, and the description of invalid ones
+func parseRules(data []byte) ([]appRule, []string, error) {
+	var result []appRule
+	err := json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var valid []appRule
+	var problems []string
+	for i, r := range result {
+		if r.Class == "" {
+			problems = append(problems, fmt.Sprintf("rule #%v: no class regex", i+1))
+			continue
+		}
+		r.re, err = regexp.Compile(r.Class)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rule #%v: invalid class regex '%s': %s", i+1, r.Class, err))
+			continue
+		}
+		valid = append(valid, r)
+	}
+	return valid, problems, nil
+}
+
+// Returns the first rule matching the class, or nil
+func classRule(class string) *appRule {
+	for i := range rules {
+		if rules[i].re.MatchString(class) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Returns the rule for a dock item ID, which may be either a class, or an alias/group name set by the rule.
+// Aliases only count for IDs no class rule matches, as several rules may share an alias w/ a class of its own.
+func ruleFor(ID string) *appRule {
+	for i := range rules {
+		if rules[i].Group != "" && rules[i].Group == ID {
+			return &rules[i]
+		}
+	}
+	if r := classRule(ID); r != nil {
+		return r
+	}
+	for i := range rules {
+		if rules[i].Alias != "" && rules[i].Alias == ID {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Returns the ID the client is represented by on the dock: group or alias name if a rule sets any, otherwise the class
+func itemID(c client) string {
+	if r := classRule(c.Class); r != nil {
+		if r.Group != "" {
+			return r.Group
+		}
+		if r.Alias != "" {
+			return r.Alias
+		}
+	}
+	return c.Class
+}
+
+// Clients forced into their own group need the exact ID to match
+func isGrouped(c client) bool {
+	r := classRule(c.Class)
+	return r != nil && r.Group != ""
+}
+
+func isHidden(c client) bool {
+	r := classRule(c.Class)
+	return r != nil && r.Hide
+}
+
+// Returns the desktop ID to look the icon, name and command up with
+func desktopID(ID string) string {
+	if r := ruleFor(ID); r != nil && r.Alias != "" {
+		return r.Alias
+	}
+	return strings.TrimSpace(ID)
+}
+
+func isActive(c client) bool {
+	return activeClient != nil && itemID(c) == itemID(*activeClient)
+}
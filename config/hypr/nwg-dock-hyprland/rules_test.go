@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+const exampleRules = `[
+  {"class": "^polkit-gnome-authentication-agent-1$", "hide": true},
+  {"class": "^Code$", "alias": "code-oss"},
+  {"class": "^firefox$", "icon": "/usr/share/icons/my-firefox.svg", "name": "Web"},
+  {"class": "^firefox-work$", "group": "firefox-work", "alias": "firefox"}
+]`
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		valid    int
+		problems int
+		wantErr  bool
+	}{
+		{"example", exampleRules, 4, 0, false},
+		{"empty list", `[]`, 0, 0, false},
+		{"no class", `[{"alias": "foot"}]`, 0, 1, false},
+		{"invalid regex", `[{"class": "^foo($"}, {"class": "^foot$"}]`, 1, 1, false},
+		{"not json", `{"class": `, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, problems, err := parseRules([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if len(valid) != tt.valid {
+				t.Errorf("got %v valid rules, want %v", len(valid), tt.valid)
+			}
+			if len(problems) != tt.problems {
+				t.Errorf("got problems %q, want %v", problems, tt.problems)
+			}
+			for _, r := range valid {
+				if r.re == nil {
+					t.Errorf("rule %q has no compiled regex", r.Class)
+				}
+			}
+		})
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	var err error
+	rules, _, err = parseRules([]byte(exampleRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { rules = nil }()
+
+	tests := []struct {
+		ID    string
+		class string
+	}{
+		{"firefox", "^firefox$"},
+		{"firefox-work", "^firefox-work$"},
+		{"code-oss", "^Code$"},
+		{"Code", "^Code$"},
+		{"foot", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ID, func(t *testing.T) {
+			r := ruleFor(tt.ID)
+			class := ""
+			if r != nil {
+				class = r.Class
+			}
+			if class != tt.class {
+				t.Errorf("ruleFor(%q) matched %q, want %q", tt.ID, class, tt.class)
+			}
+		})
+	}
+}
+
+func TestItemID(t *testing.T) {
+	var err error
+	rules, _, err = parseRules([]byte(exampleRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { rules = nil }()
+
+	tests := []struct {
+		class string
+		ID    string
+	}{
+		{"firefox", "firefox"},
+		{"firefox-work", "firefox-work"},
+		{"Code", "code-oss"},
+		{"foot", "foot"},
+	}
+	for _, tt := range tests {
+		if ID := itemID(client{Class: tt.class}); ID != tt.ID {
+			t.Errorf("itemID(%q) = %q, want %q", tt.class, ID, tt.ID)
+		}
+	}
+}
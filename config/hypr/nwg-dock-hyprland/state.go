@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Dock state, as streamed to `ctl watch` clients, one json document per change
+type dockState struct {
+	Visible     bool           `json:"visible"`
+	Output      string         `json:"output,omitempty"`
+	ActiveClass string         `json:"activeClass"`
+	ActiveItem  string         `json:"activeItem"`
+	Pinned      []string       `json:"pinned"`
+	Running     []runningClass `json:"running"`
+	Items       []itemInfo     `json:"items"`
+}
+
+type runningClass struct {
+	Class     string `json:"class"`
+	Instances int    `json:"instances"`
+}
+
+var (
+	watchers      = make(map[chan []byte]bool)
+	watchersMutex sync.Mutex
+	lastState     []byte
+)
+
+func currentState() dockState {
+	state := dockState{
+		Pinned:  []string{},
+		Running: []runningClass{},
+		Items:   []itemInfo{},
+	}
+	// e.g. all outputs gone in the multi-monitor mode
+	d := focusedDock()
+	if d == nil {
+		return state
+	}
+	state.Visible = docksVisible()
+	state.Output = d.output
+	state.Pinned = append(state.Pinned, d.pinned...)
+	state.Items = d.listItems()
+	if activeClient != nil {
+		state.ActiveClass = activeClient.Class
+		state.ActiveItem = itemID(*activeClient)
+	}
+
+	var classes []string
+	counts := make(map[string]int)
+	for _, c := range clients {
+		if c.Class == "" {
+			continue
+		}
+		if counts[c.Class] == 0 {
+			classes = append(classes, c.Class)
+		}
+		counts[c.Class]++
+	}
+	for _, class := range classes {
+		state.Running = append(state.Running, runningClass{Class: class, Instances: counts[class]})
+	}
+	return state
+}
+
+// Sends the current state to watchers, if changed; to be called from the GTK main loop
+func broadcastState() {
+	watchersMutex.Lock()
+	defer watchersMutex.Unlock()
+	if len(watchers) == 0 {
+		return
+	}
+
+	out, err := json.Marshal(currentState())
+	if err != nil {
+		log.Warnf("Error encoding dock state: %s", err)
+		return
+	}
+	if string(out) == string(lastState) {
+		return
+	}
+	lastState = out
+
+	for ch := range watchers {
+		select {
+		case ch <- out:
+		default:
+			log.Debug("State watcher too slow, skipping update")
+		}
+	}
+}
+
+// Streams the dock state to the control socket connection, until the client disconnects
+func watchState(conn *net.UnixConn) {
+	ch := make(chan []byte, 16)
+
+	initial := make(chan []byte, 1)
+	inMainLoop(func() ctlReply {
+		out, _ := json.Marshal(currentState())
+		watchersMutex.Lock()
+		watchers[ch] = true
+		lastState = out
+		watchersMutex.Unlock()
+		initial <- out
+		return ctlReply{Ok: true}
+	})
+
+	defer func() {
+		watchersMutex.Lock()
+		delete(watchers, ch)
+		watchersMutex.Unlock()
+	}()
+
+	// we don't expect anything from the client, but EOF
+	gone := make(chan bool)
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	writer := bufio.NewWriter(conn)
+	out := <-initial
+	for {
+		_, err := writer.Write(append(out, '\n'))
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			log.Debugf("State watcher gone: %s", err)
+			return
+		}
+		select {
+		case out = <-ch:
+		case <-gone:
+			log.Debug("State watcher disconnected")
+			return
+		}
+	}
+}
@@ -4,40 +4,41 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/gotk3/gotk3/gdk"
-	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
-func taskInstances(ID string) []client {
+// Clients of the item, out of the given ones
+func taskInstances(ID string, from []client) []client {
 	var found []client
-	for _, c := range clients {
-		if strings.Contains(strings.ToUpper(c.Class), strings.ToUpper(ID)) {
+	for _, c := range from {
+		cID := itemID(c)
+		if cID == ID || (!isGrouped(c) && strings.Contains(strings.ToUpper(cID), strings.ToUpper(ID))) {
 			found = append(found, c)
 		}
 	}
 	return found
 }
 
-func pinnedButton(ID string) *gtk.Box {
+func (d *dock) pinnedButton(ID string) (*gtk.Box, *gtk.Button) {
 	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
 	button, _ := gtk.ButtonNew()
-	box.PackStart(button, false, false, 0)
+	box.PackStart(d.withLauncherEntry(button, ID), false, false, 0)
 
-	image, err := createImage(ID, imgSizeScaled)
+	image, err := createImage(ID, imgSizeScaled, d.iconScale)
 	if err != nil || image == nil {
-		pixbuf, err := gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/icon-missing.svg"),
-			imgSizeScaled, imgSizeScaled)
-		if err == nil {
-			image, _ = gtk.ImageNewFromPixbuf(pixbuf)
-		} else {
+		image, err = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/icon-missing.svg"),
+			imgSizeScaled, imgSizeScaled, d.iconScale)
+		if err != nil {
 			image, _ = gtk.ImageNew()
 		}
 	}
@@ -46,18 +47,15 @@ func pinnedButton(ID string) *gtk.Box {
 	button.SetImagePosition(gtk.POS_TOP)
 	button.SetAlwaysShowImage(true)
 	button.SetTooltipText(getName(ID))
-	pixbuf, err := gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-empty.svg"),
-		imgSizeScaled, imgSizeScaled/8)
-	var img *gtk.Image
+	img, err := imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-empty.svg"),
+		imgSizeScaled, imgSizeScaled/8, d.iconScale)
 	if err == nil {
-		img, err = gtk.ImageNewFromPixbuf(pixbuf)
-		if err == nil {
-			box.PackStart(img, false, false, 0)
-		}
+		box.PackStart(img, false, false, 0)
 	}
 
 	button.Connect("clicked", func() {
 		launch(ID)
+		leaveKeyboardMode()
 	})
 
 	button.Connect("button-release-event", func(btn *gtk.Button, e *gdk.Event) bool {
@@ -66,22 +64,22 @@ func pinnedButton(ID string) *gtk.Box {
 			launch(ID)
 			return true
 		} else if btnEvent.Button() == 3 {
-			contextMenu := pinnedMenuContext(ID)
-			contextMenu.PopupAtWidget(button, widgetAnchor, menuAnchor, nil)
+			contextMenu := d.pinnedMenuContext(ID)
+			d.popup(&contextMenu, button)
 			return true
 		}
 		return false
 	})
 
-	button.Connect("enter-notify-event", cancelClose)
-	return box
+	button.Connect("enter-notify-event", d.cancelClose)
+	return box, button
 }
 
-func pinnedMenuContext(taskID string) gtk.Menu {
+func (d *dock) pinnedMenuContext(taskID string) gtk.Menu {
 	menu, _ := gtk.MenuNew()
 	menuItem, _ := gtk.MenuItemNewWithLabel("Unpin")
 	menuItem.Connect("activate", func() {
-		unpinTask(taskID)
+		d.unpin(taskID)
 	})
 	menu.Append(menuItem)
 
@@ -89,18 +87,21 @@ func pinnedMenuContext(taskID string) gtk.Menu {
 	return *menu
 }
 
-func launcherButton() *gtk.Button {
+func (d *dock) launcherButton() *gtk.Button {
 	if !*noLauncher && *launcherCmd != "" {
 		button, _ := gtk.ButtonNew()
-		var pixbuf *gdk.Pixbuf
+		var image *gtk.Image
 		var e error
 		if *ico == "" {
-			pixbuf, e = gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/grid.svg"), imgSizeScaled, imgSizeScaled)
+			image, e = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/grid.svg"), imgSizeScaled, imgSizeScaled, d.iconScale)
 		} else {
-			pixbuf, e = createPixbuf(*ico, imgSizeScaled)
+			var pixbuf *gdk.Pixbuf
+			pixbuf, e = createPixbuf(*ico, imgSizeScaled*d.iconScale)
+			if e == nil {
+				image, e = imageFromPixbuf(pixbuf, d.iconScale)
+			}
 		}
 		if e == nil {
-			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
 			button.SetImage(image)
 			button.SetAlwaysShowImage(true)
 
@@ -116,41 +117,31 @@ func launcherButton() *gtk.Button {
 				}()
 
 				if *autohide {
-					win.Hide()
+					hideDocks()
 				}
 			})
-			button.Connect("enter-notify-event", cancelClose)
+			button.Connect("enter-notify-event", d.cancelClose)
 		}
 		return button
 	}
 	return nil
 }
 
-/*
-Window on-leave-notify event hides the dock with glib Timeout 1000 ms.
-We might have left the window by accident, so let's clear the timeout if window re-entered.
-Furthermore - hovering a button triggers window on-leave-notify event, and the timeout
-needs to be cleared as well.
-*/
-func cancelClose() {
-	if src > 0 {
-		glib.SourceRemove(src)
-		src = 0
-	}
-}
-
-func taskButton(t client, instances []client) *gtk.Box {
+func (d *dock) taskButton(t client, instances []client) (*gtk.Box, *gtk.Button) {
+	ID := itemID(t)
 	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
 	button, _ := gtk.ButtonNew()
-	box.PackStart(button, false, false, 0)
+	box.PackStart(d.withLauncherEntry(button, ID), false, false, 0)
+	markUrgentButton(button, instances)
+	if allMinimized(instances) {
+		ctx, _ := button.GetStyleContext()
+		ctx.AddClass("minimized")
+	}
 
-	image, _ := createImage(t.Class, imgSizeScaled)
+	image, _ := createImage(ID, imgSizeScaled, d.iconScale)
 	if image == nil {
-		pixbuf, err := gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/icon-missing.svg"),
-			imgSizeScaled, imgSizeScaled)
-		if err == nil {
-			image, _ = gtk.ImageNewFromPixbuf(pixbuf)
-		}
+		image, _ = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/icon-missing.svg"),
+			imgSizeScaled, imgSizeScaled, d.iconScale)
 	}
 
 	if image != nil {
@@ -158,52 +149,47 @@ func taskButton(t client, instances []client) *gtk.Box {
 		button.SetImagePosition(gtk.POS_TOP)
 		button.SetAlwaysShowImage(true)
 	}
-	button.SetTooltipText(getName(t.Class))
+	player := mediaPlayerFor(ID, instances)
+	button.SetTooltipText(mediaTooltip(getName(ID), player))
+	connectMediaSeek(button, player)
 
 	var img *gtk.Image
 	if len(instances) < 2 {
-		pixbuf, err := gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-single.svg"),
-			imgSizeScaled, imgSizeScaled/8)
-		if err == nil {
-			img, _ = gtk.ImageNewFromPixbuf(pixbuf)
-		}
+		img, _ = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-single.svg"),
+			imgSizeScaled, imgSizeScaled/8, d.iconScale)
 	} else {
-		pixbuf, err := gdk.PixbufNewFromFileAtSize(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-multiple.svg"),
-			imgSizeScaled, imgSizeScaled/8)
-		if err == nil {
-			img, _ = gtk.ImageNewFromPixbuf(pixbuf)
-		}
+		img, _ = imageFromFile(filepath.Join(dataHome, "nwg-dock-hyprland/images/task-multiple.svg"),
+			imgSizeScaled, imgSizeScaled/8, d.iconScale)
 	}
 	if img != nil {
 		box.PackStart(img, false, false, 0)
 	}
-	button.Connect("enter-notify-event", cancelClose)
+	button.Connect("enter-notify-event", d.cancelClose)
+
+	// Enter or Space in the keyboard mode; mouse clicks are handled below, and don't get here
+	button.Connect("clicked", func() {
+		if len(instances) == 1 {
+			focusClient(t)
+			leaveKeyboardMode()
+		} else {
+			menu := clientMenu(ID, instances)
+			d.popup(&menu, button)
+		}
+	})
 
 	if len(instances) == 1 {
 		button.Connect("event", func(btn *gtk.Button, e *gdk.Event) bool {
 			btnEvent := gdk.EventButtonNewFromEvent(e)
 			if btnEvent.Type() == gdk.EVENT_BUTTON_RELEASE || btnEvent.Type() == gdk.EVENT_TOUCH_END {
 				if btnEvent.Button() == 1 || btnEvent.Type() == gdk.EVENT_TOUCH_END {
-					cmd := fmt.Sprintf("dispatch focuswindow address:%s", t.Address)
-					if strings.HasPrefix(t.Workspace.Name, "special") {
-						_, specialName, _ := strings.Cut(t.Workspace.Name, "special:")
-						cmd = fmt.Sprintf("dispatch togglespecialworkspace %s", specialName)
-					}
-					reply, _ := hyprctl(cmd)
-					log.Debugf("%s -> %s", cmd, reply)
-
-					// fix #14
-					cmd = "dispatch bringactivetotop"
-					reply, _ = hyprctl(cmd)
-					log.Debugf("%s -> %s", cmd, reply)
-
+					focusClient(t)
 					return true
 				} else if btnEvent.Button() == 2 {
-					launch(t.Class)
+					launch(ID)
 					return true
 				} else if btnEvent.Button() == 3 {
-					contextMenu := clientMenuContext(t.Class, instances)
-					contextMenu.PopupAtWidget(button, widgetAnchor, menuAnchor, nil)
+					contextMenu := d.clientMenuContext(ID, instances)
+					d.popup(&contextMenu, button)
 					return true
 				}
 			}
@@ -213,28 +199,70 @@ func taskButton(t client, instances []client) *gtk.Box {
 		button.Connect("button-release-event", func(btn *gtk.Button, e *gdk.Event) bool {
 			btnEvent := gdk.EventButtonNewFromEvent(e)
 			if btnEvent.Button() == 1 {
-				menu := clientMenu(t.Class, instances)
-				menu.PopupAtWidget(button, widgetAnchor, menuAnchor, nil)
+				menu := clientMenu(ID, instances)
+				d.popup(&menu, button)
 				return true
 			} else if btnEvent.Button() == 2 {
-				launch(t.Class)
+				launch(ID)
 				return true
 			} else if btnEvent.Button() == 3 {
-				contextMenu := clientMenuContext(t.Class, instances)
-				contextMenu.PopupAtWidget(button, widgetAnchor, menuAnchor, nil)
+				contextMenu := d.clientMenuContext(ID, instances)
+				d.popup(&contextMenu, button)
 				return true
 			}
 			return false
 		})
 	}
 
-	return box
+	return box, button
+}
+
+// Focuses the window, or toggles the special workspace it lives on
+func focusClient(c client) {
+	if isMinimized(c) {
+		restoreClient(c, true)
+		return
+	}
+	dispatcher := fmt.Sprintf("focuswindow address:%s", c.Address)
+	if strings.HasPrefix(c.Workspace.Name, "special") {
+		_, specialName, _ := strings.Cut(c.Workspace.Name, "special:")
+		dispatcher = fmt.Sprintf("togglespecialworkspace %s", specialName)
+	}
+	_, _ = hyprDispatch(dispatcher, &c)
+
+	// fix #14
+	_, _ = hyprDispatch("bringactivetotop", &c)
+}
+
+// Launches the item if not running, focuses its window if one, cycles through windows if several; returns the action taken
+func activateItem(item dockItem) string {
+	if len(item.Instances) == 0 {
+		launch(item.ID)
+		return "launched"
+	}
+	if len(item.Instances) == 1 {
+		focusClient(item.Instances[0])
+		return "focused"
+	}
+
+	// the next window after the active one, if it belongs to the item
+	next := 0
+	if active, err := getActiveWindow(); err == nil {
+		for i, c := range item.Instances {
+			if c.Address == active.Address {
+				next = (i + 1) % len(item.Instances)
+				break
+			}
+		}
+	}
+	focusClient(item.Instances[next])
+	return "cycled"
 }
 
 func clientMenu(class string, instances []client) gtk.Menu {
 	menu, _ := gtk.MenuNew()
 
-	iconName, err := getIcon(class)
+	iconName, err := getItemIcon(class)
 	if err != nil {
 		log.Warn(err)
 	}
@@ -247,25 +275,15 @@ func clientMenu(class string, instances []client) gtk.Menu {
 		if len(title) > 25 {
 			title = title[:25]
 		}
-		wsName := instance.Workspace.Name
 		var label *gtk.Label
-		label, _ = gtk.LabelNew(fmt.Sprintf("%s (%v)", title, instance.Workspace.Name))
+		label, _ = gtk.LabelNew(fmt.Sprintf("%s (%v)", title, workspaceLabel(instance)))
 		hbox.PackStart(label, false, false, 0)
 		menuItem.Add(hbox)
 		menu.Append(menuItem)
-		a := instance.Address
+		c := instance
 		menuItem.Connect("activate", func() {
-			cmd := fmt.Sprintf("dispatch focuswindow address:%s", a)
-			if strings.HasPrefix(wsName, "special") {
-				_, specialName, _ := strings.Cut(wsName, "special:")
-				cmd = fmt.Sprintf("dispatch togglespecialworkspace %s", specialName)
-			}
-			reply, _ := hyprctl(cmd)
-			log.Debugf("%s -> %s", cmd, reply)
-
-			cmd = "dispatch bringactivetotop"
-			reply, _ = hyprctl(cmd)
-			log.Debugf("%s -> %s", cmd, reply)
+			focusClient(c)
+			leaveKeyboardMode()
 		})
 
 	}
@@ -273,10 +291,11 @@ func clientMenu(class string, instances []client) gtk.Menu {
 	return *menu
 }
 
-func clientMenuContext(class string, instances []client) gtk.Menu {
+func (d *dock) clientMenuContext(class string, instances []client) gtk.Menu {
 	menu, _ := gtk.MenuNew()
+	mediaMenuItems(menu, mediaPlayerFor(class, instances))
 
-	iconName, err := getIcon(class)
+	iconName, err := getItemIcon(class)
 	if err != nil {
 		log.Warnf("%s %s", err, class)
 	}
@@ -296,38 +315,45 @@ func clientMenuContext(class string, instances []client) gtk.Menu {
 		//	}
 		//	return r
 		//}, title)
-		label, _ := gtk.LabelNew(fmt.Sprintf("%s (%v)", title, instance.Workspace.Name))
+		label, _ := gtk.LabelNew(fmt.Sprintf("%s (%v)", title, workspaceLabel(instance)))
 		hbox.PackStart(label, false, false, 0)
 		menuItem.Add(hbox)
 		menu.Append(menuItem)
 		submenu, _ := gtk.MenuNew()
 
-		a := instance.Address
+		c := instance
 
 		subitem, _ := gtk.MenuItemNewWithLabel("closewindow")
 		submenu.Append(subitem)
 		subitem.Connect("activate", func() {
-			cmd := fmt.Sprintf("dispatch closewindow address:%s", a)
-			reply, _ := hyprctl(cmd)
-			log.Debugf("%s -> %s", cmd, reply)
+			_, _ = hyprDispatch(fmt.Sprintf("closewindow address:%s", c.Address), &c)
 		})
 
 		subitem, _ = gtk.MenuItemNewWithLabel("togglefloating")
 		submenu.Append(subitem)
 		subitem.Connect("activate", func() {
-			cmd := fmt.Sprintf("dispatch togglefloating address:%s", a)
-			reply, _ := hyprctl(cmd)
-			log.Debugf("%s -> %s", cmd, reply)
+			_, _ = hyprDispatch(fmt.Sprintf("togglefloating address:%s", c.Address), &c)
 		})
 
 		subitem, _ = gtk.MenuItemNewWithLabel("fullscreen")
 		submenu.Append(subitem)
 		subitem.Connect("activate", func() {
-			cmd := fmt.Sprintf("dispatch fullscreen address:%s", a)
-			reply, _ := hyprctl(cmd)
-			log.Debugf("%s -> %s", cmd, reply)
+			_, _ = hyprDispatch(fmt.Sprintf("fullscreen address:%s", c.Address), &c)
 		})
 
+		if isMinimized(c) {
+			subitem, _ = gtk.MenuItemNewWithLabel("restore")
+			subitem.Connect("activate", func() {
+				restoreClient(c, true)
+			})
+		} else {
+			subitem, _ = gtk.MenuItemNewWithLabel("minimize")
+			subitem.Connect("activate", func() {
+				minimizeClient(c)
+			})
+		}
+		submenu.Append(subitem)
+
 		s, _ := gtk.SeparatorMenuItemNew()
 		submenu.Append(s)
 
@@ -335,9 +361,7 @@ func clientMenuContext(class string, instances []client) gtk.Menu {
 			subItem, _ := gtk.MenuItemNewWithLabel(fmt.Sprintf("-> WS %v", i))
 			target := i
 			subItem.Connect("activate", func() {
-				cmd := fmt.Sprintf("dispatch movetoworkspace %v,address:%v", target, a)
-				reply, _ := hyprctl(cmd)
-				log.Debugf("%s -> %s", cmd, reply)
+				_, _ = hyprDispatch(fmt.Sprintf("movetoworkspace %v,address:%v", target, c.Address), &c)
 			})
 			submenu.Append(subItem)
 		}
@@ -357,26 +381,23 @@ func clientMenuContext(class string, instances []client) gtk.Menu {
 	closeAllWindows.SetLabel("Close all windows")
 	closeAllWindows.Connect("activate", func() {
 		for _, instance := range instances {
-			address := instance.Address
-			cmd := fmt.Sprintf("dispatch closewindow address:%s", address)
-			reply, _ := hyprctl(cmd)
-			log.Infof("%s -> %s", cmd, reply)
+			_, _ = hyprDispatch(fmt.Sprintf("closewindow address:%s", instance.Address), &instance)
 		}
 	})
 	menu.Append(closeAllWindows)
 
 	pinItem, _ := gtk.MenuItemNew()
-	if !inPinned(class) {
+	if !d.isPinned(class) {
 		pinItem.SetLabel("Pin")
 		pinItem.Connect("activate", func() {
 			log.Infof("pin %s", class)
-			pinTask(class)
+			d.pin(class)
 		})
 	} else {
 		pinItem.SetLabel("Unpin")
 		pinItem.Connect("activate", func() {
 			log.Infof("unpin %s", class)
-			unpinTask(class)
+			d.unpin(class)
 		})
 	}
 	menu.Append(pinItem)
@@ -385,7 +406,7 @@ func clientMenuContext(class string, instances []client) gtk.Menu {
 	return *menu
 }
 
-func inPinned(taskID string) bool {
+func inPinned(pinned []string, taskID string) bool {
 	for _, id := range pinned {
 		if strings.TrimSpace(taskID) == strings.TrimSpace(id) {
 			return true
@@ -394,27 +415,58 @@ func inPinned(taskID string) bool {
 	return false
 }
 
-func inTasks(pinID string) bool {
-	for _, task := range clients {
-		if strings.TrimSpace(task.Class) == strings.TrimSpace(pinID) {
+func inTasks(pinID string, from []client) bool {
+	for _, task := range from {
+		if strings.TrimSpace(itemID(task)) == strings.TrimSpace(pinID) {
 			return true
 		}
 	}
 	return false
 }
 
-func createImage(appID string, size int) (*gtk.Image, error) {
-	name, err := getIcon(appID)
+// Creates the item icon of the size in logical pixels, w/ the scale factor of the monitor it's going to be shown on
+func createImage(appID string, size, scale int) (*gtk.Image, error) {
+	name, err := getItemIcon(appID)
 	if err != nil {
 		name = appID
 	}
-	pixbuf, e := createPixbuf(name, size)
+	pixbuf, e := createPixbuf(name, size*scale)
 	if e != nil {
 		return nil, err
 	}
-	image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+	return imageFromPixbuf(pixbuf, scale)
+}
+
+func imageFromFile(path string, width, height, scale int) (*gtk.Image, error) {
+	pixbuf, err := gdk.PixbufNewFromFileAtSize(path, width*scale, height*scale)
+	if err != nil {
+		return nil, err
+	}
+	return imageFromPixbuf(pixbuf, scale)
+}
+
+/*
+A pixbuf shown in a GtkImage takes as many logical pixels as it has, and gets upscaled on HiDPI outputs.
+We load pixbufs at size * scale instead, and wrap them in a cairo surface w/ the device scale, so that they
+take the intended logical size, and get drawn 1:1 in physical pixels.
+*/
+func imageFromPixbuf(pixbuf *gdk.Pixbuf, scale int) (*gtk.Image, error) {
+	if scale <= 1 {
+		return gtk.ImageNewFromPixbuf(pixbuf)
+	}
+	surface, err := gdk.CairoSurfaceCreateFromPixbuf(pixbuf, scale, nil)
+	if err != nil {
+		return nil, err
+	}
+	return gtk.ImageNewFromSurface(surface)
+}
 
-	return image, nil
+// Returns the icon name or path for the dock item, honouring the icon override and alias rules
+func getItemIcon(ID string) (string, error) {
+	if r := ruleFor(ID); r != nil && r.Icon != "" {
+		return r.Icon, nil
+	}
+	return getIcon(desktopID(ID))
 }
 
 func createPixbuf(icon string, size int) (*gdk.Pixbuf, error) {
@@ -712,6 +764,10 @@ func getExec(appName string) (string, error) {
 
 func getName(appName string) string {
 	name := appName
+	if r := ruleFor(appName); r != nil && r.Name != "" {
+		return r.Name
+	}
+	appName = desktopID(appName)
 	for _, d := range appDirs {
 		files, _ := os.ReadDir(d)
 		path := ""
@@ -767,22 +823,6 @@ func loadTextFile(path string) ([]string, error) {
 	return output, nil
 }
 
-func pinTask(itemID string) {
-	for _, item := range pinned {
-		if item == itemID {
-			println(item, "already pinned")
-			return
-		}
-	}
-	pinned = append(pinned, itemID)
-	savePinned()
-}
-
-func unpinTask(itemID string) {
-	pinned = remove(pinned, itemID)
-	savePinned()
-}
-
 func remove(s []string, r string) []string {
 	for i, v := range s {
 		if v == r {
@@ -792,8 +832,8 @@ func remove(s []string, r string) []string {
 	return s
 }
 
-func savePinned() {
-	f, err := os.OpenFile(pinnedFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+func savePinned(path string, pinned []string) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -812,7 +852,7 @@ func savePinned() {
 }
 
 func launch(ID string) {
-	command, err := getExec(ID)
+	command, err := getExec(desktopID(ID))
 	if err != nil {
 		log.Errorf("%s", err)
 	}
@@ -856,40 +896,110 @@ func launch(ID string) {
 		cmd.Env = append(cmd.Env, envVars...)
 	}
 
-	msg := fmt.Sprintf("env vars: %s; command: '%s'; args: %s\n", envVars, elements[cmdIdx], args)
-	log.Info(msg)
+	entry := log.WithFields(log.Fields{"item": ID, "command": elements[cmdIdx], "args": args, "env": envVars})
+	entry.Info("Launch")
 
 	if err := cmd.Start(); err != nil {
-		log.Error("Unable to launch command!", err.Error())
+		entry.WithError(err).Error("Unable to launch command!")
 	}
 
 	if *autohide {
-		win.Hide()
+		hideDocks()
 	}
 }
 
-// Returns map output name -> gdk.Monitor
+/*
+Returns map output name -> gdk.Monitor. We can't rely on the order of monitors, as it differs between GDK and Hyprland
+once monitors get hot-plugged, so we match them by the connector name (on Wayland GDK reports it as the model, if the
+compositor supports xdg-output v2), the model, or the geometry. Outputs w/o a matching GDK monitor are left out.
+*/
 func mapOutputs() (map[string]*gdk.Monitor, error) {
 	result := make(map[string]*gdk.Monitor)
 
 	err := listMonitors()
 	if err != nil {
-		log.Fatalf("Error listing monitors: %v", err)
+		return nil, fmt.Errorf("error listing monitors: %w", err)
 	}
 
 	display, err := gdk.DisplayGetDefault()
 	if err != nil {
-		log.Fatalf("Error finding default GDK display: %v", err)
+		return nil, fmt.Errorf("error finding default GDK display: %w", err)
+	}
+	var gdkMonitors []*gdk.Monitor
+	for i := 0; i < display.GetNMonitors(); i++ {
+		mon, err := display.GetMonitor(i)
+		if err == nil {
+			gdkMonitors = append(gdkMonitors, mon)
+		}
 	}
 
-	num := display.GetNMonitors()
-	for i := 0; i < num; i++ {
-		mon, _ := display.GetMonitor(i)
-		result[monitors[i].Name] = mon
+	matched := make(map[int]bool)
+	// one pass per criterion over outputs not matched yet, so the fallbacks only get monitors left over
+	matchBy := func(matches func(m monitor, mon *gdk.Monitor) bool) {
+		for _, m := range monitors {
+			if _, ok := result[m.Name]; ok {
+				continue
+			}
+			for i, mon := range gdkMonitors {
+				if !matched[i] && matches(m, mon) {
+					matched[i] = true
+					result[m.Name] = mon
+					break
+				}
+			}
+		}
+	}
+
+	matchBy(func(m monitor, mon *gdk.Monitor) bool { return mon.GetModel() == m.Name })
+	// models may repeat, so let's check the geometry as well, if possible
+	matchBy(func(m monitor, mon *gdk.Monitor) bool { return mon.GetModel() == m.Model && sameGeometry(m, mon) })
+	matchBy(func(m monitor, mon *gdk.Monitor) bool { return sameGeometry(m, mon) })
+	matchBy(func(m monitor, mon *gdk.Monitor) bool { return m.Model != "" && mon.GetModel() == m.Model })
+
+	for _, m := range monitors {
+		if _, ok := result[m.Name]; !ok {
+			log.Warnf("No GDK monitor matches output %s", m.Name)
+		}
 	}
 	return result, nil
 }
 
+// Size of the monitor in the layout coordinates: Hyprland reports it in physical pixels, before the transform
+func logicalSize(m monitor) (int, int) {
+	if m.Scale <= 0 {
+		return 0, 0
+	}
+	width := int(math.Round(float64(m.Width) / m.Scale))
+	height := int(math.Round(float64(m.Height) / m.Scale))
+	// rotated by 90 or 270 degrees
+	if m.Transform%2 == 1 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// Compares the logical geometry of Hyprland and GDK monitors
+func sameGeometry(m monitor, mon *gdk.Monitor) bool {
+	if m.Scale <= 0 {
+		return false
+	}
+	width, height := logicalSize(m)
+	x, y, w, h := mon.GetGeometry().GetRectangleInt()
+	return x == m.X && y == m.Y && w == width && h == height
+}
+
+// Returns the GDK monitor of the output, or an error listing available outputs, if not found
+func outputMonitor(output2mon map[string]*gdk.Monitor, name string) (*gdk.Monitor, error) {
+	if mon, ok := output2mon[name]; ok {
+		return mon, nil
+	}
+	var available []string
+	for _, m := range monitors {
+		available = append(available, m.Name)
+	}
+	return nil, fmt.Errorf("output '%s' not found, available outputs: %s", name, strings.Join(available, ", "))
+}
+
 func listGdkMonitors() ([]gdk.Monitor, error) {
 	var monitors []gdk.Monitor
 	display, err := gdk.DisplayGetDefault()
@@ -920,6 +1030,56 @@ func isCommand(command string) bool {
 	return getCommandOutput(fmt.Sprintf("command -v %s ", cmd)) != ""
 }
 
+// Appends the instance name, if any, to the name of a file or namespace the dock instance owns
+func instanceID(base string) string {
+	if *instanceName == "" {
+		return base
+	}
+	return fmt.Sprintf("%s-%s", base, *instanceName)
+}
+
+// Base name of the lock file and the control socket: md5-hashed $USER name, and the instance name, if any
+func userInstanceID() string {
+	return instanceID(fmt.Sprintf("nwg-dock-%s", md5Hash(os.Getenv("USER"))))
+}
+
+func isValidInstanceName(name string) bool {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the css file name the dock instance uses
+func effectiveCssFileName() string {
+	if *instanceName != "" && !isFlagPassed("s") {
+		return fmt.Sprintf("%s.css", instanceID("style"))
+	}
+	return *cssFileName
+}
+
+// Returns the launcher command to use if none given, or an empty string if no known launcher installed
+func detectLauncherCmd() string {
+	if isCommand("nwg-drawer") {
+		return "nwg-drawer"
+	} else if isCommand("nwggrid") {
+		return "nwggrid -p"
+	}
+	return ""
+}
+
+func isFlagPassed(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
 func md5Hash(text string) string {
 	hash := md5.Sum([]byte(text))
 	return hex.EncodeToString(hash[:])
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+System tray section, implementing the StatusNotifierHost side of the StatusNotifierItem protocol. If no other program
+(e.g. a bar) owns the org.kde.StatusNotifierWatcher name, we become the watcher as well. Context menus are built from
+the com.canonical.dbusmenu interface of the item.
+*/
+
+const (
+	sniWatcherName  = "org.kde.StatusNotifierWatcher"
+	sniWatcherPath  = dbus.ObjectPath("/StatusNotifierWatcher")
+	sniItemIface    = "org.kde.StatusNotifierItem"
+	sniDefaultPath  = dbus.ObjectPath("/StatusNotifierItem")
+	dbusMenuIface   = "com.canonical.dbusmenu"
+	dbusPropsGetAll = "org.freedesktop.DBus.Properties.GetAll"
+)
+
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte // ARGB32, network byte order
+}
+
+type trayItem struct {
+	key           string // "bus name/object path", as registered w/ the watcher
+	service       string
+	owner         string // unique bus name, to match item signals w/
+	path          dbus.ObjectPath
+	id            string
+	title         string
+	status        string
+	iconName      string
+	iconThemePath string
+	iconPixmaps   []sniPixmap
+	tooltip       string
+	menuPath      dbus.ObjectPath
+	itemIsMenu    bool
+}
+
+// (ia{sv}av) dbusmenu layout node
+type dbusMenuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+var (
+	trayConn    *dbus.Conn
+	trayItems   []*trayItem
+	trayMutex   sync.Mutex
+	trayWatcher *sniWatcher
+
+	// added to the default icon theme search path already; used in the main loop only
+	trayThemePaths []string
+)
+
+// StatusNotifierWatcher, exported if nobody else provides it
+type sniWatcher struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+	items []string
+	hosts []string
+	mutex sync.Mutex
+}
+
+func (w *sniWatcher) RegisterStatusNotifierItem(sender dbus.Sender, service string) *dbus.Error {
+	// Some items register w/ the object path only, e.g. "/org/ayatana/NotificationItem/foo"
+	key := fmt.Sprintf("%s%s", service, sniDefaultPath)
+	if strings.HasPrefix(service, "/") {
+		key = fmt.Sprintf("%s%s", sender, service)
+	} else if strings.Contains(service, "/") {
+		key = service
+	}
+
+	w.mutex.Lock()
+	if isIn(w.items, key) {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.items = append(w.items, key)
+	items := append([]string{}, w.items...)
+	w.mutex.Unlock()
+
+	w.props.SetMust(sniWatcherName, "RegisteredStatusNotifierItems", items)
+	_ = w.conn.Emit(sniWatcherPath, fmt.Sprintf("%s.StatusNotifierItemRegistered", sniWatcherName), key)
+	log.Debugf("SNI watcher: item registered: %s", key)
+	return nil
+}
+
+func (w *sniWatcher) RegisterStatusNotifierHost(sender dbus.Sender, service string) *dbus.Error {
+	w.mutex.Lock()
+	if !isIn(w.hosts, string(sender)) {
+		w.hosts = append(w.hosts, string(sender))
+	}
+	w.mutex.Unlock()
+
+	w.props.SetMust(sniWatcherName, "IsStatusNotifierHostRegistered", true)
+	_ = w.conn.Emit(sniWatcherPath, fmt.Sprintf("%s.StatusNotifierHostRegistered", sniWatcherName))
+	return nil
+}
+
+// Forgets items whose bus name has gone
+func (w *sniWatcher) nameLost(name string) {
+	w.mutex.Lock()
+	var kept, lost []string
+	for _, key := range w.items {
+		if strings.HasPrefix(key, name+"/") {
+			lost = append(lost, key)
+		} else {
+			kept = append(kept, key)
+		}
+	}
+	w.items = kept
+	w.hosts = remove(w.hosts, name)
+	items := append([]string{}, w.items...)
+	w.mutex.Unlock()
+
+	if len(lost) > 0 {
+		w.props.SetMust(sniWatcherName, "RegisteredStatusNotifierItems", items)
+	}
+	for _, key := range lost {
+		_ = w.conn.Emit(sniWatcherPath, fmt.Sprintf("%s.StatusNotifierItemUnregistered", sniWatcherName), key)
+		log.Debugf("SNI watcher: item unregistered: %s", key)
+	}
+}
+
+func startTrayWatcher(conn *dbus.Conn) error {
+	w := &sniWatcher{conn: conn}
+	err := conn.Export(w, sniWatcherPath, sniWatcherName)
+	if err != nil {
+		return err
+	}
+	w.props, err = prop.Export(conn, sniWatcherPath, prop.Map{
+		sniWatcherName: {
+			"RegisteredStatusNotifierItems":  {Value: []string{}, Writable: false, Emit: prop.EmitTrue},
+			"IsStatusNotifierHostRegistered": {Value: false, Writable: false, Emit: prop.EmitTrue},
+			"ProtocolVersion":                {Value: int32(0), Writable: false, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	reply, err := conn.RequestName(sniWatcherName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		// someone else is the watcher, fine
+		conn.Export(nil, sniWatcherPath, sniWatcherName)
+		return nil
+	}
+	trayWatcher = w
+	log.Info("SNI: acting as the StatusNotifierWatcher")
+	return nil
+}
+
+// Connects to the session bus as the StatusNotifierHost, and the watcher if needed
+func startTray() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	trayConn = conn
+
+	err = startTrayWatcher(conn)
+	if err != nil {
+		log.Warnf("SNI: couldn't start the watcher: %s", err)
+	}
+
+	for _, match := range [][]dbus.MatchOption{
+		{dbus.WithMatchInterface(sniWatcherName)},
+		{dbus.WithMatchInterface(sniItemIface)},
+		{dbus.WithMatchInterface("org.freedesktop.DBus"), dbus.WithMatchMember("NameOwnerChanged")},
+	} {
+		err = conn.AddMatchSignal(match...)
+		if err != nil {
+			return err
+		}
+	}
+	signals := make(chan *dbus.Signal, 20)
+	conn.Signal(signals)
+
+	hostName := fmt.Sprintf("org.kde.StatusNotifierHost-%v", os.Getpid())
+	_, err = conn.RequestName(hostName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		call := conn.Object(sniWatcherName, sniWatcherPath).Call(
+			fmt.Sprintf("%s.RegisterStatusNotifierHost", sniWatcherName), 0, hostName)
+		if call.Err != nil {
+			log.Warnf("SNI: couldn't register host: %s", call.Err)
+		}
+
+		v, err := conn.Object(sniWatcherName, sniWatcherPath).GetProperty(
+			fmt.Sprintf("%s.RegisteredStatusNotifierItems", sniWatcherName))
+		if err == nil {
+			keys, _ := v.Value().([]string)
+			for _, key := range keys {
+				addTrayItem(key)
+			}
+		}
+
+		for s := range signals {
+			handleTraySignal(s)
+		}
+	}()
+	return nil
+}
+
+func handleTraySignal(s *dbus.Signal) {
+	if len(s.Body) == 0 && !strings.HasPrefix(s.Name, sniItemIface+".New") {
+		return
+	}
+	switch {
+	case s.Name == fmt.Sprintf("%s.StatusNotifierItemRegistered", sniWatcherName):
+		if key, ok := s.Body[0].(string); ok {
+			addTrayItem(key)
+		}
+	case s.Name == fmt.Sprintf("%s.StatusNotifierItemUnregistered", sniWatcherName):
+		if key, ok := s.Body[0].(string); ok {
+			removeTrayItems(func(item *trayItem) bool { return item.key == key })
+		}
+	case s.Name == "org.freedesktop.DBus.NameOwnerChanged" && len(s.Body) == 3:
+		name, _ := s.Body[0].(string)
+		newOwner, _ := s.Body[2].(string)
+		if name != "" && newOwner == "" {
+			if trayWatcher != nil {
+				trayWatcher.nameLost(name)
+			}
+			removeTrayItems(func(item *trayItem) bool { return item.service == name || item.owner == name })
+		}
+	case strings.HasPrefix(s.Name, sniItemIface+".New"):
+		// NewIcon, NewTitle, NewToolTip, NewStatus, NewAttentionIcon, NewMenu...
+		trayMutex.Lock()
+		var changed *trayItem
+		for _, item := range trayItems {
+			if item.owner == s.Sender && item.path == s.Path {
+				changed = item
+				break
+			}
+		}
+		trayMutex.Unlock()
+		if changed != nil {
+			updateTrayItem(changed)
+			refreshMainBox(true)
+		}
+	}
+}
+
+func addTrayItem(key string) {
+	service, path, found := strings.Cut(key, "/")
+	item := &trayItem{key: key, service: service, path: sniDefaultPath}
+	if found {
+		item.path = dbus.ObjectPath("/" + path)
+	}
+
+	err := trayConn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, service).Store(&item.owner)
+	if err != nil {
+		log.Debugf("SNI: item %s has no owner: %s", key, err)
+		return
+	}
+
+	trayMutex.Lock()
+	for _, existing := range trayItems {
+		if existing.key == key {
+			trayMutex.Unlock()
+			return
+		}
+	}
+	trayItems = append(trayItems, item)
+	trayMutex.Unlock()
+
+	updateTrayItem(item)
+	log.Debugf("SNI: item added: %s (%s)", key, item.id)
+	refreshMainBox(true)
+}
+
+func removeTrayItems(match func(item *trayItem) bool) {
+	trayMutex.Lock()
+	var kept []*trayItem
+	for _, item := range trayItems {
+		if !match(item) {
+			kept = append(kept, item)
+		}
+	}
+	changed := len(kept) != len(trayItems)
+	trayItems = kept
+	trayMutex.Unlock()
+
+	if changed {
+		refreshMainBox(true)
+	}
+}
+
+// Re-reads the item properties
+func updateTrayItem(item *trayItem) {
+	var props map[string]dbus.Variant
+	err := trayConn.Object(item.service, item.path).Call(dbusPropsGetAll, 0, sniItemIface).Store(&props)
+	if err != nil {
+		log.Debugf("SNI: couldn't get %s properties: %s", item.key, err)
+		return
+	}
+
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	item.id, _ = props["Id"].Value().(string)
+	item.title, _ = props["Title"].Value().(string)
+	item.status, _ = props["Status"].Value().(string)
+	item.iconName, _ = props["IconName"].Value().(string)
+	item.iconThemePath, _ = props["IconThemePath"].Value().(string)
+	item.itemIsMenu, _ = props["ItemIsMenu"].Value().(bool)
+	item.menuPath, _ = props["Menu"].Value().(dbus.ObjectPath)
+
+	item.iconPixmaps = nil
+	if v, ok := props["IconPixmap"]; ok {
+		_ = v.Store(&item.iconPixmaps)
+	}
+	// items in the NeedsAttention status may provide a different icon
+	if item.status == "NeedsAttention" {
+		if name, _ := props["AttentionIconName"].Value().(string); name != "" {
+			item.iconName = name
+		}
+	}
+
+	item.tooltip = item.title
+	if v, ok := props["ToolTip"]; ok {
+		var tooltip struct {
+			IconName    string
+			IconPixmaps []sniPixmap
+			Title       string
+			Description string
+		}
+		if v.Store(&tooltip) == nil && tooltip.Title != "" {
+			item.tooltip = tooltip.Title
+			if tooltip.Description != "" {
+				item.tooltip = fmt.Sprintf("%s\n%s", tooltip.Title, tooltip.Description)
+			}
+		}
+	}
+}
+
+// Returns the tray section, or nil if disabled or empty; to be packed after the launcher button
+func (d *dock) trayBox() *gtk.Box {
+	if !*showTray {
+		return nil
+	}
+
+	trayMutex.Lock()
+	var items []trayItem
+	for _, item := range trayItems {
+		if item.status != "Passive" {
+			items = append(items, *item)
+		}
+	}
+	trayMutex.Unlock()
+	if len(items) == 0 {
+		return nil
+	}
+
+	box, _ := gtk.BoxNew(innerOrientation, 0)
+	_ = box.SetProperty("name", "tray")
+	for _, item := range items {
+		box.PackStart(d.trayButton(item), false, false, 0)
+	}
+	return box
+}
+
+func (d *dock) trayButton(item trayItem) *gtk.Button {
+	button, _ := gtk.ButtonNew()
+	pixbuf := trayPixbuf(item, imgSizeScaled*d.iconScale)
+	if pixbuf != nil {
+		image, _ := imageFromPixbuf(pixbuf, d.iconScale)
+		button.SetImage(image)
+		button.SetAlwaysShowImage(true)
+	} else {
+		button.SetLabel(item.title)
+	}
+	button.SetTooltipText(item.tooltip)
+	button.Connect("enter-notify-event", d.cancelClose)
+
+	obj := trayConn.Object(item.service, item.path)
+	button.Connect("button-release-event", func(btn *gtk.Button, e *gdk.Event) bool {
+		btnEvent := gdk.EventButtonNewFromEvent(e)
+		switch {
+		case btnEvent.Button() == 1 && !item.itemIsMenu:
+			go obj.Call(fmt.Sprintf("%s.Activate", sniItemIface), 0, int32(0), int32(0))
+		case btnEvent.Button() == 2:
+			go obj.Call(fmt.Sprintf("%s.SecondaryActivate", sniItemIface), 0, int32(0), int32(0))
+		case btnEvent.Button() == 1 || btnEvent.Button() == 3:
+			if item.menuPath != "" {
+				go d.popupTrayMenu(item, button)
+			} else {
+				go obj.Call(fmt.Sprintf("%s.ContextMenu", sniItemIface), 0, int32(0), int32(0))
+			}
+		default:
+			return false
+		}
+		return true
+	})
+
+	return button
+}
+
+// Returns the icon from the theme, the item theme path, or the pixmap best matching the size
+func trayPixbuf(item trayItem, size int) *gdk.Pixbuf {
+	if item.iconName != "" {
+		if strings.HasPrefix(item.iconName, "/") {
+			pixbuf, err := gdk.PixbufNewFromFileAtSize(item.iconName, size, size)
+			if err == nil {
+				return pixbuf
+			}
+		}
+		iconTheme, err := gtk.IconThemeGetDefault()
+		if err == nil {
+			if item.iconThemePath != "" && !isIn(trayThemePaths, item.iconThemePath) {
+				iconTheme.AppendSearchPath(item.iconThemePath)
+				trayThemePaths = append(trayThemePaths, item.iconThemePath)
+			}
+			pixbuf, err := iconTheme.LoadIcon(item.iconName, size, gtk.ICON_LOOKUP_FORCE_SIZE)
+			if err == nil {
+				return pixbuf
+			}
+		}
+	}
+
+	var best *sniPixmap
+	for i, p := range item.iconPixmaps {
+		if len(p.Data) != int(p.Width*p.Height*4) || p.Width <= 0 {
+			continue
+		}
+		if best == nil || (best.Width < int32(size) && p.Width > best.Width) ||
+			(p.Width >= int32(size) && p.Width < best.Width) {
+			best = &item.iconPixmaps[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	// ARGB -> RGBA
+	rgba := make([]byte, len(best.Data))
+	for i := 0; i < len(best.Data); i += 4 {
+		rgba[i], rgba[i+1], rgba[i+2], rgba[i+3] = best.Data[i+1], best.Data[i+2], best.Data[i+3], best.Data[i]
+	}
+	pixbuf, err := gdk.PixbufNewFromBytes(rgba, gdk.COLORSPACE_RGB, true, 8, int(best.Width), int(best.Height),
+		int(best.Width)*4)
+	if err != nil {
+		return nil
+	}
+	if int(best.Width) != size {
+		scaled, err := pixbuf.ScaleSimple(size, size, gdk.INTERP_BILINEAR)
+		if err == nil {
+			return scaled
+		}
+	}
+	return pixbuf
+}
+
+// Fetches the dbusmenu layout, and pops the menu up in the main loop
+func (d *dock) popupTrayMenu(item trayItem, button *gtk.Button) {
+	obj := trayConn.Object(item.service, item.menuPath)
+	obj.Call(fmt.Sprintf("%s.AboutToShow", dbusMenuIface), 0, int32(0))
+
+	var revision uint32
+	var layout dbusMenuLayout
+	err := obj.Call(fmt.Sprintf("%s.GetLayout", dbusMenuIface), 0, int32(0), int32(-1), []string{}).Store(&revision, &layout)
+	if err != nil {
+		log.Warnf("SNI: couldn't get %s menu: %s", item.key, err)
+		return
+	}
+
+	glib.IdleAdd(func() bool {
+		menu, _ := gtk.MenuNew()
+		appendTrayMenuItems(menu, obj, layout.Children)
+		menu.ShowAll()
+		d.popup(menu, button)
+		return false
+	})
+}
+
+func appendTrayMenuItems(menu *gtk.Menu, obj dbus.BusObject, children []dbus.Variant) {
+	for _, child := range children {
+		var node dbusMenuLayout
+		err := dbus.Store([]interface{}{child.Value()}, &node)
+		if err != nil {
+			continue
+		}
+		if visible, ok := node.Properties["visible"].Value().(bool); ok && !visible {
+			continue
+		}
+
+		if t, _ := node.Properties["type"].Value().(string); t == "separator" {
+			separator, _ := gtk.SeparatorMenuItemNew()
+			menu.Append(separator)
+			continue
+		}
+
+		label, _ := node.Properties["label"].Value().(string)
+		var menuItem *gtk.MenuItem
+		if toggleType, _ := node.Properties["toggle-type"].Value().(string); toggleType != "" {
+			checkItem, _ := gtk.CheckMenuItemNewWithMnemonic(label)
+			state, _ := node.Properties["toggle-state"].Value().(int32)
+			checkItem.SetActive(state == 1)
+			menuItem = &checkItem.MenuItem
+		} else {
+			menuItem, _ = gtk.MenuItemNewWithMnemonic(label)
+		}
+		if enabled, ok := node.Properties["enabled"].Value().(bool); ok && !enabled {
+			menuItem.SetSensitive(false)
+		}
+
+		if len(node.Children) > 0 {
+			submenu, _ := gtk.MenuNew()
+			appendTrayMenuItems(submenu, obj, node.Children)
+			menuItem.SetSubmenu(submenu)
+		} else {
+			id := node.ID
+			menuItem.Connect("activate", func() {
+				go obj.Call(fmt.Sprintf("%s.Event", dbusMenuIface), 0, id, "clicked", dbus.MakeVariant(""),
+					uint32(time.Now().Unix()))
+			})
+		}
+		menu.Append(menuItem)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Apps publish badges, progress and urgency w/ the com.canonical.Unity.LauncherEntry.Update(s app_uri, a{sv} properties)
+signal, where app_uri is "application://<desktop ID>.desktop". We keep the last known state per desktop ID, and render
+it on dock buttons w/ the "badge" label, the "progress" bar and the "urgent" button style classes.
+*/
+type launcherEntry struct {
+	Count           int64
+	CountVisible    bool
+	Progress        float64
+	ProgressVisible bool
+	Urgent          bool
+
+	sender string
+}
+
+// Progress bar of a dock button, updated in place while the progress changes
+type progressBar struct {
+	ID  string
+	bar *gtk.ProgressBar
+}
+
+var (
+	launcherEntries      = make(map[string]launcherEntry)
+	launcherEntriesMutex sync.Mutex
+)
+
+// Subscribes to LauncherEntry signals on the session bus
+func startLauncherEntryListener() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+
+	err = conn.AddMatchSignal(dbus.WithMatchInterface("com.canonical.Unity.LauncherEntry"), dbus.WithMatchMember("Update"))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	// to forget entries of apps that quit
+	err = conn.AddMatchSignal(dbus.WithMatchInterface("org.freedesktop.DBus"), dbus.WithMatchMember("NameOwnerChanged"))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		for s := range signals {
+			var changed, progressChanged bool
+			switch s.Name {
+			case "com.canonical.Unity.LauncherEntry.Update":
+				changed, progressChanged = updateLauncherEntry(s)
+			case "org.freedesktop.DBus.NameOwnerChanged":
+				changed = dropLauncherEntries(s)
+			}
+			if changed && refreshMainBox != nil {
+				refreshMainBox(true)
+			} else if progressChanged {
+				glib.IdleAdd(updateProgressBars)
+			}
+		}
+	}()
+	return nil
+}
+
+// Returns whether the dock needs rebuilding, and whether just the value of a visible progress bar changed
+func updateLauncherEntry(s *dbus.Signal) (bool, bool) {
+	if len(s.Body) < 2 {
+		return false, false
+	}
+	uri, ok := s.Body[0].(string)
+	if !ok {
+		return false, false
+	}
+	props, ok := s.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return false, false
+	}
+
+	ID := strings.TrimSuffix(strings.TrimPrefix(uri, "application://"), ".desktop")
+	if ID == "" {
+		return false, false
+	}
+
+	launcherEntriesMutex.Lock()
+	defer launcherEntriesMutex.Unlock()
+
+	entry := launcherEntries[ID]
+	old := entry
+	entry.sender = s.Sender
+	for key, v := range props {
+		switch key {
+		case "count":
+			entry.Count = variantInt(v)
+		case "count-visible":
+			entry.CountVisible, _ = v.Value().(bool)
+		case "progress":
+			entry.Progress, _ = v.Value().(float64)
+		case "progress-visible":
+			entry.ProgressVisible, _ = v.Value().(bool)
+		case "urgent":
+			entry.Urgent, _ = v.Value().(bool)
+		}
+	}
+	launcherEntries[ID] = entry
+	log.Debugf("LauncherEntry %s: %+v", ID, entry)
+
+	// progress may be updated very often, let's not rebuild the dock for invisible changes, nor for the progress value
+	if entry.rendered() != old.rendered() {
+		return true, false
+	}
+	return false, entry.rendered().ProgressVisible && entry.Progress != old.Progress
+}
+
+// Returns what the dock shows of the entry, w/o the progress value
+func (e launcherEntry) rendered() launcherEntry {
+	r := launcherEntry{Urgent: e.Urgent}
+	if e.CountVisible && e.Count > 0 {
+		r.CountVisible, r.Count = true, e.Count
+	}
+	r.ProgressVisible = e.ProgressVisible && e.Progress > 0
+	return r
+}
+
+// Sets progress bars of all docks to the last known values
+func updateProgressBars() {
+	for _, d := range docks {
+		for _, p := range d.progressBars {
+			if entry, ok := launcherEntryFor(p.ID); ok {
+				p.bar.SetFraction(entry.Progress)
+			}
+		}
+	}
+}
+
+func dropLauncherEntries(s *dbus.Signal) bool {
+	if len(s.Body) < 3 {
+		return false
+	}
+	name, _ := s.Body[0].(string)
+	newOwner, _ := s.Body[2].(string)
+	if name == "" || newOwner != "" {
+		return false
+	}
+
+	launcherEntriesMutex.Lock()
+	defer launcherEntriesMutex.Unlock()
+
+	changed := false
+	for ID, entry := range launcherEntries {
+		if entry.sender == name {
+			delete(launcherEntries, ID)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func variantInt(v dbus.Variant) int64 {
+	switch n := v.Value().(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case byte:
+		return int64(n)
+	}
+	return 0
+}
+
+// Returns the launcher entry for a dock item ID, if any
+func launcherEntryFor(ID string) (launcherEntry, bool) {
+	launcherEntriesMutex.Lock()
+	defer launcherEntriesMutex.Unlock()
+
+	for _, candidate := range []string{ID, desktopID(ID)} {
+		for entryID, entry := range launcherEntries {
+			if strings.EqualFold(entryID, candidate) {
+				return entry, true
+			}
+		}
+	}
+	return launcherEntry{}, false
+}
+
+// Returns the button decorated w/ the badge and the progress bar, if the app published any
+func (d *dock) withLauncherEntry(button *gtk.Button, ID string) gtk.IWidget {
+	entry, ok := launcherEntryFor(ID)
+	if !ok {
+		return button
+	}
+
+	if entry.Urgent {
+		ctx, _ := button.GetStyleContext()
+		ctx.AddClass("urgent")
+	}
+
+	showCount := entry.CountVisible && entry.Count > 0
+	showProgress := entry.ProgressVisible && entry.Progress > 0
+	if !showCount && !showProgress {
+		return button
+	}
+
+	overlay, _ := gtk.OverlayNew()
+	overlay.Add(button)
+
+	if showCount {
+		text := fmt.Sprintf("%v", entry.Count)
+		if entry.Count > 99 {
+			text = "99+"
+		}
+		badge, _ := gtk.LabelNew(text)
+		ctx, _ := badge.GetStyleContext()
+		ctx.AddClass("badge")
+		badge.SetHAlign(gtk.ALIGN_END)
+		badge.SetVAlign(gtk.ALIGN_START)
+		overlay.AddOverlay(badge)
+	}
+
+	if showProgress {
+		bar, _ := gtk.ProgressBarNew()
+		bar.SetFraction(entry.Progress)
+		ctx, _ := bar.GetStyleContext()
+		ctx.AddClass("progress")
+		bar.SetVAlign(gtk.ALIGN_END)
+		bar.SetHAlign(gtk.ALIGN_FILL)
+		overlay.AddOverlay(bar)
+		d.progressBars = append(d.progressBars, progressBar{ID: ID, bar: bar})
+	}
+
+	return overlay
+}
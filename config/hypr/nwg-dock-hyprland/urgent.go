@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	log "github.com/sirupsen/logrus"
+)
+
+// Addresses (w/ the "0x" prefix, as in j/clients) of windows that requested attention, and haven't been focused since
+var (
+	urgentAddresses = make(map[string]bool)
+	urgentMutex     sync.Mutex
+)
+
+// Handles the socket2 `urgent>>ADDRESS` event, the address comes w/o the "0x" prefix
+func markUrgent(winAddr string) {
+	urgentMutex.Lock()
+	urgentAddresses["0x"+winAddr] = true
+	urgentMutex.Unlock()
+	log.Debugf("Urgent window: 0x%s", winAddr)
+
+	refreshMainBox(true)
+
+	if *autohide && *urgentReveal > 0 {
+		glib.IdleAdd(func() bool {
+			for _, d := range docks {
+				if d.isShown() || d.fullscreenHidden || (d.output != "" && !d.showsClient("0x"+winAddr)) {
+					continue
+				}
+				d.show()
+				// hide it as if the pointer left, unless the pointer enters meanwhile
+				d.cancelClose()
+				d.src = glib.TimeoutAdd(uint(*urgentReveal), func() bool {
+					d.hide()
+					d.src = 0
+					return false
+				})
+			}
+			return false
+		})
+	}
+}
+
+// Clears the urgent state on `activewindowv2` and `closewindow`; returns true if the window was urgent
+func clearUrgent(winAddr string) bool {
+	urgentMutex.Lock()
+	defer urgentMutex.Unlock()
+	if urgentAddresses["0x"+winAddr] {
+		delete(urgentAddresses, "0x"+winAddr)
+		return true
+	}
+	return false
+}
+
+func isUrgent(instances []client) bool {
+	urgentMutex.Lock()
+	defer urgentMutex.Unlock()
+	for _, c := range instances {
+		if urgentAddresses[c.Address] {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds the "urgent" (and optionally "bounce") style class to the button, if any of the instances requested attention
+func markUrgentButton(button *gtk.Button, instances []client) {
+	if !isUrgent(instances) {
+		return
+	}
+	ctx, _ := button.GetStyleContext()
+	ctx.AddClass("urgent")
+	if *urgentBounce {
+		ctx.AddClass("bounce")
+	}
+}